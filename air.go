@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// airPattern matches an AIR (AI Resource) identifier such as
+// urn:air:sdxl:lora:civitai:328553@368189, with the optional format suffix
+var airPattern = regexp.MustCompile(`(?i)\b(?:urn:)?air:([a-z0-9_.-]+):([a-z0-9_-]+):([a-z0-9_-]+):(\d+)(?:@(\d+))?(?:\.[a-z0-9]+)?`)
+
+// AIR is a parsed AIR identifier: which model, and optionally which
+// version of it, a resource is on its source site
+type AIR struct {
+	Ecosystem string // e.g. "sdxl" or "flux1"
+	Type      string // e.g. "checkpoint" or "lora"
+	Source    string // e.g. "civitai"
+	ModelID   int
+	VersionID int // 0 when the AIR names the model only
+}
+
+// ParseAIR parses an AIR identifier
+func ParseAIR(s string) (*AIR, error) {
+	s = strings.TrimSpace(s)
+	match := airPattern.FindStringSubmatch(s)
+	if match == nil || match[0] != s {
+		return nil, fmt.Errorf("not an AIR identifier: %q", s)
+	}
+
+	air := &AIR{
+		Ecosystem: strings.ToLower(match[1]),
+		Type:      strings.ToLower(match[2]),
+		Source:    strings.ToLower(match[3]),
+	}
+	air.ModelID, _ = strconv.Atoi(match[4])
+	if match[5] != "" {
+		air.VersionID, _ = strconv.Atoi(match[5])
+	}
+	return air, nil
+}
+
+// isAIR reports whether a string is an AIR identifier
+func isAIR(s string) bool {
+	_, err := ParseAIR(s)
+	return err == nil
+}
+
+// findAIR returns the first AIR identifier among a node's string inputs
+func findAIR(node WorkflowNode) string {
+	for _, value := range node.Inputs {
+		if text, ok := value.(string); ok {
+			if air := airPattern.FindString(text); air != "" {
+				return air
+			}
+		}
+	}
+	return ""
+}
+
+// ModelType maps the AIR's resource type to a model directory
+func (a *AIR) ModelType() ModelType {
+	switch a.Type {
+	case "lora", "lycoris", "locon", "dora":
+		return ModelTypeLora
+	case "embedding", "textualinversion":
+		return ModelTypeEmbedding
+	case "vae":
+		return ModelTypeVAE
+	case "controlnet":
+		return ModelTypeControlNet
+	case "upscaler":
+		return ModelTypeUpscale
+	default:
+		return ModelTypeCheckpoint
+	}
+}
+
+// GetModelByVersionID returns the primary file of a CivitAI model version
+func (c *CivitAIClient) GetModelByVersionID(versionID int, modelType ModelType) (*SearchResult, error) {
+	version, err := c.GetModelVersion(versionID)
+	if err != nil {
+		return nil, err
+	}
+	result := c.PrimaryFile(version, modelType)
+	if result == nil {
+		return nil, fmt.Errorf("no downloadable files in CivitAI version %d", versionID)
+	}
+	return result, nil
+}
+
+// GetModelByAIR resolves a CivitAI AIR identifier to its primary file. An
+// AIR without a version resolves to the model's newest version.
+func (c *CivitAIClient) GetModelByAIR(s string, modelType ModelType) (*SearchResult, error) {
+	air, err := ParseAIR(s)
+	if err != nil {
+		return nil, err
+	}
+	if air.Source != "civitai" {
+		return nil, fmt.Errorf("AIR source %q is not supported", air.Source)
+	}
+	if modelType == "" {
+		modelType = air.ModelType()
+	}
+
+	versionID := air.VersionID
+	if versionID == 0 {
+		if versionID, err = c.LatestVersionID(air.ModelID); err != nil {
+			return nil, err
+		}
+	}
+	return c.GetModelByVersionID(versionID, modelType)
+}
+
+// civitAIAIRResolver resolves models whose workflow node carries an AIR
+// straight to that CivitAI version, without searching by name
+type civitAIAIRResolver struct {
+	client *CivitAIClient
+}
+
+func (r *civitAIAIRResolver) Name() string { return "civitai air" }
+
+func (r *civitAIAIRResolver) Search(model Model) (*SearchResult, error) {
+	if model.AIR == "" {
+		return nil, fmt.Errorf("skipped, workflow has no AIR")
+	}
+	return r.client.GetModelByAIR(model.AIR, model.Type)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Alias returns what a workflow model name is configured to mean: a
+// canonical file name or a download URL. Names are matched without regard
+// to case, by full name first and then by file name.
+func (c *Config) Alias(name string) (string, bool) {
+	if len(c.Aliases) == 0 {
+		return "", false
+	}
+
+	name = normalizeModelName(name)
+	for _, key := range []string{name, path.Base(name)} {
+		for alias, target := range c.Aliases {
+			if strings.EqualFold(normalizeModelName(alias), key) {
+				return target, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isURL reports whether an alias target is a download URL
+func isURL(target string) bool {
+	return strings.Contains(target, "://")
+}
+
+// checkAlias looks for the file a model name is aliased to, updating
+// LocalPath when found
+func (s *ModelScanner) checkAlias(model *Model, cache *dirCache) bool {
+	target, ok := s.config.Alias(model.Name)
+	if !ok || isURL(target) {
+		return false
+	}
+
+	relPath := filepath.FromSlash(normalizeModelName(target))
+	candidates := []string{s.config.GetModelPath(model.Type, target)}
+	for _, dir := range s.config.SearchDirs(model.Type) {
+		candidates = append(candidates, filepath.Join(dir, relPath))
+	}
+
+	for _, candidate := range candidates {
+		if path, ok := probeModelPath(candidate, cache); ok {
+			model.LocalPath = path
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// modelRoots returns every directory models may be kept in: the ComfyUI
+// models folder plus each type's search and download directories, without
+// ones nested in another
+func (c *Config) modelRoots() []string {
+	roots := []string{filepath.Join(c.ComfyUIPath, "models")}
+	for _, modelType := range knownModelTypes {
+		roots = append(roots, c.SearchDirs(modelType)...)
+		roots = append(roots, c.downloadDir(modelType))
+	}
+
+	for i := range roots {
+		roots[i] = filepath.Clean(roots[i])
+	}
+	sort.Strings(roots)
+
+	var outer []string
+	for _, root := range roots {
+		if len(outer) > 0 {
+			last := outer[len(outer)-1]
+			if root == last || strings.HasPrefix(root, last+string(filepath.Separator)) {
+				continue
+			}
+		}
+		outer = append(outer, root)
+	}
+	return outer
+}
+
+// fileIndex returns the model files under roots by normalized file name,
+// walking the directories on first use
+func (c *dirCache) fileIndex(roots []string) map[string][]string {
+	if c.index != nil {
+		return c.index
+	}
+
+	c.index = make(map[string][]string)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if p == root {
+					return filepath.SkipDir // a missing root has no files
+				}
+				return nil
+			}
+			if entry.IsDir() || !hasModelExtension(entry.Name()) {
+				return nil
+			}
+			name := normalizeFileName(entry.Name())
+			c.index[name] = append(c.index[name], p)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to index %s: %v\n", root, err)
+		}
+	}
+	return c.index
+}
+
+// findInAnyDir looks for the model's file in every model directory, for
+// libraries where files have drifted out of the expected layout. When the
+// workflow gives a SHA256, only a file matching it counts. LocalPath is
+// updated and the location reported when found.
+func (s *ModelScanner) findInAnyDir(model *Model, cache *dirCache) bool {
+	index := cache.fileIndex(s.config.modelRoots())
+
+	base := path.Base(normalizeModelName(model.Name))
+	names := []string{base}
+	if !hasModelExtension(base) {
+		for _, ext := range probeExtensions {
+			names = append(names, base+ext)
+		}
+	}
+
+	for _, name := range names {
+		for _, candidate := range index[normalizeFileName(name)] {
+			if len(model.Hash) == 64 {
+				if hash, err := sha256File(candidate); err != nil || !strings.EqualFold(hash, model.Hash) {
+					continue
+				}
+			}
+			fmt.Printf("%s is present at %s; its expected location is %s\n",
+				model.Name, candidate, s.config.GetModelPath(model.Type, model.Name))
+			model.LocalPath = candidate
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// baseBackoff is the first retry's upper bound; it doubles each attempt
+const baseBackoff = time.Second
+
+// backoffDelay returns a full-jitter exponential delay for a retry attempt
+// (1 for the first retry): a random duration in [0, min(max, base*2^(n-1))).
+// The randomness keeps workers that failed together from retrying together.
+func backoffDelay(attempt int, max time.Duration) time.Duration {
+	ceiling := max
+	if attempt < 32 { // avoid overflowing the shift
+		if d := baseBackoff << (attempt - 1); d > 0 && d < max {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// retryTransport retries idempotent API requests that fail with a network
+// error, 429 or 5xx, using jittered exponential backoff
+type retryTransport struct {
+	base       http.RoundTripper
+	attempts   int
+	maxBackoff time.Duration
+}
+
+// newRetryTransport returns a transport that retries requests sent
+// through base as configured
+func newRetryTransport(config *Config, base http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		base:       base,
+		attempts:   config.RetryAttempts,
+		maxBackoff: config.MaxRetryBackoff,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Only requests without a body can be replayed safely
+	retryable := (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.Body == nil
+
+	for attempt := 1; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if !retryable || attempt >= t.attempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := backoffDelay(attempt, t.maxBackoff)
+		if resp != nil {
+			if after := retryAfter(resp); after > 0 && after < t.maxBackoff {
+				delay = after
+			}
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// shouldRetry reports whether a response or error is likely transient
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
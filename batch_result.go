@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// BatchResult is what happened to each model in a download batch
+type BatchResult struct {
+	Succeeded  []Model
+	Skipped    []Model // already present with a matching hash
+	Failed     []FailedDownload
+	NotStarted []Model // left in the queue by an interrupt or the failure breaker
+	OverQuota  []Model // not downloaded, or cut short, by max_total_download_bytes
+}
+
+// FailedDownload is a model that couldn't be downloaded, with the reason
+type FailedDownload struct {
+	Model Model
+	Err   error
+}
+
+// jobOutcome is a worker's report on one queued job
+type jobOutcome struct {
+	job     DownloadJob
+	err     error
+	started bool
+	skipped bool
+}
+
+// add records a job's outcome
+func (r *BatchResult) add(outcome jobOutcome) {
+	switch {
+	case errors.Is(outcome.err, ErrQuotaExceeded):
+		r.OverQuota = append(r.OverQuota, outcome.job.Model)
+	case !outcome.started:
+		r.NotStarted = append(r.NotStarted, outcome.job.Model)
+	case outcome.err != nil:
+		r.Failed = append(r.Failed, FailedDownload{Model: outcome.job.Model, Err: outcome.err})
+	case outcome.skipped:
+		r.Skipped = append(r.Skipped, outcome.job.Model)
+	default:
+		r.Succeeded = append(r.Succeeded, outcome.job.Model)
+	}
+}
+
+// sort orders every list by model name, since workers finish in any order
+func (r *BatchResult) sort() {
+	byName := func(models []Model) {
+		sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	}
+	byName(r.Succeeded)
+	byName(r.Skipped)
+	byName(r.NotStarted)
+	byName(r.OverQuota)
+	sort.Slice(r.Failed, func(i, j int) bool { return r.Failed[i].Model.Name < r.Failed[j].Model.Name })
+}
+
+// Total is the number of models in the batch
+func (r *BatchResult) Total() int {
+	return len(r.Succeeded) + len(r.Skipped) + len(r.Failed) + len(r.NotStarted) + len(r.OverQuota)
+}
+
+// PrintSummary prints how many downloads succeeded and failed, listing
+// each failure with its error
+func (r *BatchResult) PrintSummary() {
+	fmt.Printf("\n%d succeeded, %d failed", len(r.Succeeded), len(r.Failed))
+	if len(r.Skipped) > 0 {
+		fmt.Printf(", %d already present", len(r.Skipped))
+	}
+	if len(r.NotStarted) > 0 {
+		fmt.Printf(", %d not started", len(r.NotStarted))
+	}
+	if len(r.OverQuota) > 0 {
+		fmt.Printf(", %d over the download cap", len(r.OverQuota))
+	}
+	fmt.Println()
+
+	if len(r.Failed) > 0 {
+		fmt.Println("Failed:")
+		for _, failed := range r.Failed {
+			fmt.Printf("  - %s (%s): %v\n", failed.Model.Name, failed.Model.Type, failed.Err)
+		}
+	}
+	if len(r.NotStarted) > 0 {
+		fmt.Println("Not started (--resume-batch continues them):")
+		for _, model := range r.NotStarted {
+			fmt.Printf("  - %s (%s)\n", model.Name, model.Type)
+		}
+	}
+	if len(r.OverQuota) > 0 {
+		fmt.Println("Over max_total_download_bytes (left for the next run; partial files resume):")
+		for _, model := range r.OverQuota {
+			fmt.Printf("  - %s (%s)\n", model.Name, model.Type)
+		}
+	}
+}
+
+// downloadAndSummarize runs a batch and prints its summary, unless it was
+// interrupted, which prints its own
+func (m *ModelManager) downloadAndSummarize(ctx context.Context, models []Model, results map[string]SearchResult) error {
+	result, err := m.downloader.DownloadBatch(ctx, models, results)
+	if ctx.Err() == nil {
+		result.PrintSummary()
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Batch job statuses
+const (
+	JobPending   = "pending"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// BatchJobState is one resolved download and how far it got
+type BatchJobState struct {
+	Model  Model        `json:"model"`
+	Result SearchResult `json:"result"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// BatchState is the saved queue of a download batch, so an interrupted
+// batch can be continued with --resume-batch without searching again
+type BatchState struct {
+	path      string
+	mu        sync.Mutex
+	StartedAt time.Time                 `json:"started_at"`
+	Jobs      map[string]*BatchJobState `json:"jobs"` // keyed by model name
+}
+
+// BatchStateFile returns where the current batch's state is saved
+func (c *Config) BatchStateFile() string {
+	return filepath.Join(filepath.Dir(c.ProvenanceFile()), ".model-manager-batch.json")
+}
+
+// LoadBatchState reads a saved batch
+func LoadBatchState(path string) (*BatchState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no interrupted batch to resume")
+		}
+		return nil, fmt.Errorf("failed to read batch state: %w", err)
+	}
+
+	state := &BatchState{path: path}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse batch state: %w", err)
+	}
+	return state, nil
+}
+
+// update records a finished job and saves the state; a nil state is a no-op
+func (s *BatchState) update(job DownloadJob, err error, interrupted bool) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.Jobs[job.Model.Name]
+	if !ok {
+		return
+	}
+	switch {
+	case err == nil:
+		state.Status, state.Error = JobCompleted, ""
+	case interrupted:
+		state.Status, state.Error = JobPending, "" // partial .tmp resumes next time
+	default:
+		state.Status, state.Error = JobFailed, err.Error()
+	}
+
+	if err := s.save(); err != nil {
+		log.Printf("Failed to save batch state: %v\n", err)
+	}
+}
+
+// save writes the state to disk; the caller must hold s.mu
+func (s *BatchState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0644)
+}
+
+// DownloadBatch downloads models like DownloadModels, saving the queue and
+// each job's status so an interrupted batch can be resumed. The state file
+// is removed once every job has completed.
+func (d *DownloadManager) DownloadBatch(ctx context.Context, models []Model, results map[string]SearchResult) (*BatchResult, error) {
+	state := &BatchState{
+		path:      d.config.BatchStateFile(),
+		StartedAt: time.Now(),
+		Jobs:      make(map[string]*BatchJobState),
+	}
+	for _, model := range models {
+		if result, ok := results[model.Name]; ok {
+			state.Jobs[model.Name] = &BatchJobState{Model: model, Result: result, Status: JobPending}
+		}
+	}
+	if err := state.save(); err != nil {
+		log.Printf("Failed to save batch state: %v\n", err)
+	}
+
+	d.batch = state
+	defer func() { d.batch = nil }()
+
+	result, err := d.DownloadModels(ctx, models, results)
+	if err == nil {
+		os.Remove(state.path)
+	}
+	return result, err
+}
+
+// ResumeBatch continues the last interrupted batch: completed jobs are
+// verified by hash and everything else is downloaded again
+func (m *ModelManager) ResumeBatch(ctx context.Context) error {
+	state, err := LoadBatchState(m.config.BatchStateFile())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Resuming batch started %s (%d jobs)\n", state.StartedAt.Format(time.RFC1123), len(state.Jobs))
+
+	var models []Model
+	results := make(map[string]SearchResult)
+	for name, job := range state.Jobs {
+		if job.Status == JobCompleted && jobStillValid(job) {
+			fmt.Printf("  done:    %s\n", name)
+			continue
+		}
+		fmt.Printf("  %-8s %s\n", job.Status+":", name)
+		models = append(models, job.Model)
+		results[name] = job.Result
+	}
+
+	if len(models) == 0 {
+		fmt.Println("\nAll jobs in the batch are complete.")
+		os.Remove(state.path)
+		return nil
+	}
+
+	return m.downloadAndSummarize(ctx, models, results)
+}
+
+// jobStillValid checks a completed job's file is there and, when the hash
+// is known, still matches it
+func jobStillValid(job *BatchJobState) bool {
+	localPath := downloadPath(job.Model, job.Result)
+	if !fileExists(localPath) {
+		return false
+	}
+	return checkSourceHash(localPath, job.Result) == nil
+}
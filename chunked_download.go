@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minChunkSize keeps small files from being split into many tiny requests
+const minChunkSize = 16 * 1024 * 1024
+
+// errRangeIgnored is returned when a server answers a range request with
+// the whole file, so the download has to fall back to a single stream
+var errRangeIgnored = errors.New("server ignored the range request")
+
+// chunkState records how far each range of a chunked download got. It is
+// kept beside the preallocated partial file, whose size says nothing
+// about progress, and only ever describes data already synced to disk.
+type chunkState struct {
+	Size   int64       `json:"size"`
+	Chunks []chunkSpan `json:"chunks"`
+}
+
+// chunkSpan is the byte range [Start, End] of one chunk and how many of
+// its bytes are on disk
+type chunkSpan struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+// next returns the first missing offset of the chunk, past End when
+// it is complete
+func (c chunkSpan) next() int64 {
+	return c.Start + c.Done
+}
+
+// chunkStatePath returns where a partial file's chunk progress is kept
+func chunkStatePath(destPath string) string {
+	return destPath + ".chunks"
+}
+
+// newChunkState splits size bytes into chunks ranges
+func newChunkState(size int64, chunks int) *chunkState {
+	state := &chunkState{Size: size}
+	chunkSize := size / int64(chunks)
+	for i := 0; i < chunks; i++ {
+		start := int64(i) * chunkSize
+		end := start + chunkSize - 1
+		if i == chunks-1 {
+			end = size - 1
+		}
+		state.Chunks = append(state.Chunks, chunkSpan{Start: start, End: end})
+	}
+	return state
+}
+
+// loadChunkState returns the saved progress of a chunked partial file of
+// size bytes, or nil when there is none or it doesn't match the file
+func loadChunkState(destPath string, size int64) *chunkState {
+	data, err := os.ReadFile(chunkStatePath(destPath))
+	if err != nil {
+		return nil
+	}
+	var state chunkState
+	if err := json.Unmarshal(data, &state); err != nil || len(state.Chunks) == 0 {
+		return nil
+	}
+	if size > 0 && state.Size != size {
+		return nil
+	}
+	if info, err := os.Stat(destPath); err != nil || info.Size() != state.Size {
+		return nil
+	}
+	return &state
+}
+
+// save writes the state beside the partial file
+func (s *chunkState) save(destPath string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(chunkStatePath(destPath), data, 0644)
+}
+
+// done returns the bytes of the file on disk
+func (s *chunkState) done() int64 {
+	var total int64
+	for _, chunk := range s.Chunks {
+		total += chunk.Done
+	}
+	return total
+}
+
+// frontier returns the end of the file's complete prefix
+func (s *chunkState) frontier() int64 {
+	for _, chunk := range s.Chunks {
+		if chunk.next() <= chunk.End {
+			return chunk.next()
+		}
+	}
+	return s.Size
+}
+
+// partialBytes returns how much of a partial download is on disk: the
+// chunk progress of a chunked one, or else the file's size
+func partialBytes(destPath string) int64 {
+	if state := loadChunkState(destPath, 0); state != nil {
+		return state.done()
+	}
+	if info, err := os.Stat(destPath); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// removePartial deletes a partial download and any chunk progress saved
+// for it
+func removePartial(destPath string) error {
+	os.Remove(chunkStatePath(destPath))
+	return os.Remove(destPath)
+}
+
+// useChunks returns how many parallel range requests to use for a file,
+// or 1 for a single stream. A chunked partial file resumes chunked, even
+// if chunks_per_file has changed since.
+func useChunks(chunks int, info headInfo, destPath string) int {
+	if state := loadChunkState(destPath, 0); state != nil {
+		if info.AcceptRanges && state.Size == info.Size {
+			return len(state.Chunks)
+		}
+		// A preallocated file can't be resumed as a stream
+		removePartial(destPath)
+	}
+	if !info.AcceptRanges || info.Size <= 0 {
+		return 1
+	}
+	if chunks <= 1 {
+		return 1
+	}
+	// A partial single-stream download resumes with a Range instead
+	if fileExists(destPath) {
+		return 1
+	}
+	if max := int(info.Size / minChunkSize); chunks > max {
+		chunks = max
+	}
+	if chunks < 1 {
+		return 1
+	}
+	return chunks
+}
+
+// chunkedDownload is one run of downloadChunked
+type chunkedDownload struct {
+	client    *http.Client
+	base      *http.Request
+	destPath  string
+	file      *os.File
+	syncEvery int64
+	wrap      func(io.Reader) io.Reader
+	check     func(*http.Response) error
+
+	mu         sync.Mutex // guards state and unsynced
+	state      *chunkState
+	unsynced   int64
+	saveMu     sync.Mutex // serializes syncs and state saves
+	downloaded atomic.Int64
+	onProgress func(downloaded, total int64)
+
+	hashMu   sync.Mutex // guards sum
+	sum      *streamSum
+	hashStop bool // hashing failed; verifyDownload reads the file instead
+}
+
+// downloadChunked downloads size bytes with parallel range requests into
+// destPath, preallocated, like streamDownload does in one stream: each
+// response is vetted by check, the file is flushed every syncEvery bytes,
+// dropped connections are reopened where they stopped, and sum hashes the
+// file in order as its complete prefix grows. Progress is saved beside
+// the file so a failed or interrupted download resumes its chunks. When
+// the server ignores ranges it falls back to a single stream.
+func downloadChunked(client *http.Client, req *http.Request, destPath string, size int64, chunks int, syncEvery int64,
+	sum *streamSum, wrap func(io.Reader) io.Reader, check func(*http.Response) error, onProgress func(downloaded, total int64)) error {
+	state := loadChunkState(destPath, size)
+	fresh := state == nil
+	if fresh {
+		state = newChunkState(size, chunks)
+	}
+
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if fresh {
+		if err := file.Truncate(size); err != nil {
+			file.Close()
+			os.Remove(destPath)
+			return err
+		}
+		if err := state.save(destPath); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to save chunk progress: %w", err)
+		}
+	}
+
+	// Hashing restarts from the beginning of the file
+	if err := sum.resume(destPath, 0); err != nil {
+		file.Close()
+		return err
+	}
+
+	d := &chunkedDownload{
+		client: client, base: req, destPath: destPath, file: file,
+		syncEvery: syncEvery, wrap: wrap, check: check,
+		state: state, onProgress: onProgress, sum: sum,
+	}
+	d.downloaded.Store(state.done())
+	if onProgress != nil {
+		onProgress(d.downloaded.Load(), size)
+	}
+
+	err = d.run(req.Context())
+	if errors.Is(err, errRangeIgnored) {
+		file.Close()
+		removePartial(destPath)
+		log.Printf("%s ignored a range request; downloading in one stream\n", redactedURL(req.URL))
+		return streamDownload(client, req, destPath, syncEvery, sum, wrap, check, onProgress)
+	}
+	if err != nil {
+		// Keep what arrived for the next attempt
+		d.persist()
+		file.Close()
+		return err
+	}
+
+	d.advanceHash(true)
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	os.Remove(chunkStatePath(destPath))
+	return nil
+}
+
+// run downloads every unfinished chunk in parallel, returning the first
+// error after cancelling the others
+func (d *chunkedDownload) run(parent context.Context) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := range d.state.Chunks {
+		if d.state.Chunks[i].next() > d.state.Chunks[i].End {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := d.downloadChunk(ctx, i); err != nil {
+				mu.Lock()
+				// The first real failure, not the cancellations it caused
+				if firstErr == nil || (errors.Is(firstErr, context.Canceled) && parent.Err() == nil) {
+					firstErr = err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// downloadChunk fetches the rest of chunk i, reopening a connection that
+// drops mid-stream the way streamDownload does
+func (d *chunkedDownload) downloadChunk(ctx context.Context, i int) error {
+	streaming, failures := false, 0
+	for {
+		progressed, err := d.fetchRange(ctx, i)
+		if err == nil {
+			return nil
+		}
+		if progressed {
+			streaming, failures = true, 0
+		}
+		if !streaming || !isConnectionError(err) || ctx.Err() != nil || failures >= maxStreamReconnects {
+			return err
+		}
+		failures++
+		log.Printf("Connection lost downloading part of %s (%v), reconnecting\n", redactedURL(d.base.URL), redactError(err))
+
+		select {
+		case <-time.After(backoffDelay(failures, 10*time.Second)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// fetchRange makes one request for the rest of chunk i and writes it at
+// its offset, reporting whether any bytes arrived
+func (d *chunkedDownload) fetchRange(ctx context.Context, i int) (bool, error) {
+	d.mu.Lock()
+	chunk := d.state.Chunks[i]
+	d.mu.Unlock()
+	start, end := chunk.next(), chunk.End
+	if start > end {
+		return false, nil
+	}
+
+	req := d.base.Clone(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if err := d.check(resp); err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return false, errRangeIgnored
+	}
+	if first, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || first != start {
+		return false, errRangeIgnored
+	}
+
+	reader := d.wrap(resp.Body)
+	buf := make([]byte, 1024*1024)
+	offset := start
+	for offset <= end {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if int64(n) > end-offset+1 {
+				n = int(end - offset + 1)
+			}
+			if _, err := d.file.WriteAt(buf[:n], offset); err != nil {
+				return offset > start, err
+			}
+			offset += int64(n)
+			d.wrote(i, int64(n))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return offset > start, err
+		}
+	}
+
+	if offset != end+1 {
+		return offset > start, fmt.Errorf("incomplete range, received %d of %d bytes: %w",
+			offset-start, end-start+1, io.ErrUnexpectedEOF)
+	}
+	return true, nil
+}
+
+// wrote records n bytes written to chunk i, reports progress, and syncs
+// and saves progress every syncEvery bytes
+func (d *chunkedDownload) wrote(i int, n int64) {
+	d.mu.Lock()
+	d.state.Chunks[i].Done += n
+	d.unsynced += n
+	flush := d.syncEvery > 0 && d.unsynced >= d.syncEvery
+	if flush {
+		d.unsynced = 0
+	}
+	d.mu.Unlock()
+
+	total := d.downloaded.Add(n)
+	if d.onProgress != nil {
+		d.mu.Lock()
+		d.onProgress(total, d.state.Size)
+		d.mu.Unlock()
+	}
+
+	d.advanceHash(false)
+	if flush {
+		d.persist()
+	}
+}
+
+// persist syncs the file and then saves the progress it now holds, so
+// the saved state never claims bytes that didn't reach the disk
+func (d *chunkedDownload) persist() {
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	// Bytes counted before the sync were written before it
+	d.mu.Lock()
+	snapshot := chunkState{Size: d.state.Size, Chunks: append([]chunkSpan(nil), d.state.Chunks...)}
+	d.mu.Unlock()
+
+	if err := d.file.Sync(); err != nil {
+		log.Printf("Failed to sync %s: %v\n", d.destPath, err)
+		return
+	}
+	if err := snapshot.save(d.destPath); err != nil {
+		log.Printf("Failed to save chunk progress for %s: %v\n", d.destPath, err)
+	}
+}
+
+// advanceHash feeds sum the file's newly completed prefix, read back while
+// it is still in the page cache. Writers pass wait false and skip while
+// another goroutine is hashing; the final call waits and hashes the rest.
+func (d *chunkedDownload) advanceHash(wait bool) {
+	if d.sum == nil {
+		return
+	}
+	if wait {
+		d.hashMu.Lock()
+	} else if !d.hashMu.TryLock() {
+		return
+	}
+	defer d.hashMu.Unlock()
+	if d.hashStop {
+		return
+	}
+
+	d.mu.Lock()
+	frontier := d.state.frontier()
+	d.mu.Unlock()
+
+	if hashed := d.sum.size; frontier > hashed {
+		section := io.NewSectionReader(d.file, hashed, frontier-hashed)
+		if _, err := io.Copy(d.sum, section); err != nil {
+			log.Printf("Failed to hash %s while downloading: %v\n", d.destPath, err)
+			d.hashStop = true
+		}
+	}
+}
@@ -1,19 +1,41 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 )
 
+// ErrRequiresPurchase is returned when CivitAI serves a web page instead of
+// the file, which happens for early-access and paywalled models
+var ErrRequiresPurchase = errors.New("file requires authentication or purchase")
+
 // CivitAIClient handles searching and downloading from CivitAI
 type CivitAIClient struct {
+	baseURL    string // see SetBaseURL
 	token      string
 	httpClient *http.Client
+	allowNSFW  bool
+	cache      *responseCache
+	policy     FormatPolicy
+	headers    map[string]string // custom auth headers, see authorize
+	tokenParam string            // query parameter carrying the token on downloads
+	trusted    trustList         // creators searches may return; nil allows all
+
+	// Downloads run far longer than API calls, so they use a client
+	// without an overall timeout and rely on stall detection instead
+	downloadClient *http.Client
+	stallTimeout   time.Duration
+	chunks         int   // parallel range requests per file
+	syncEvery      int64 // bytes between flushes of a partial file
 }
 
 // CivitAISearchResponse represents the CivitAI search API response
@@ -33,6 +55,8 @@ type CivitAIModel struct {
 	ID            int                   `json:"id"`
 	Name          string                `json:"name"`
 	Type          string                `json:"type"`
+	NSFW          bool                  `json:"nsfw"`
+	NSFWLevel     int                   `json:"nsfwLevel"`
 	ModelVersions []CivitAIModelVersion `json:"modelVersions"`
 	Creator       struct {
 		Username string `json:"username"`
@@ -48,6 +72,11 @@ type CivitAIModelVersion struct {
 	Images      []struct {
 		URL string `json:"url"`
 	} `json:"images"`
+	ModelID int `json:"modelId"`
+	Model   struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"model"`
 }
 
 // CivitAIModelFile represents a downloadable file
@@ -59,6 +88,7 @@ type CivitAIModelFile struct {
 	Format           string  `json:"format"`
 	PickleScanResult string  `json:"pickleScanResult"`
 	VirusScanResult  string  `json:"virusScanResult"`
+	Primary          bool    `json:"primary"` // served by the version's plain download link
 	Hashes           struct {
 		SHA256 string `json:"SHA256"`
 		AutoV1 string `json:"AutoV1"`
@@ -66,24 +96,49 @@ type CivitAIModelFile struct {
 		CRC32  string `json:"CRC32"`
 		BLAKE3 string `json:"BLAKE3"`
 	} `json:"hashes"`
+	// Metadata tells apart the variants a version offers, e.g. a pruned
+	// fp16 SafeTensor next to the full fp32 one
+	Metadata struct {
+		Format string `json:"format"`
+		Size   string `json:"size"`
+		FP     string `json:"fp"`
+	} `json:"metadata"`
 	DownloadURL string `json:"downloadUrl"`
 }
 
+// defaultCivitAIBaseURL is where CivitAI's site and API are served
+const defaultCivitAIBaseURL = "https://civitai.com"
+
 // NewCivitAIClient creates a new CivitAI client
 func NewCivitAIClient(token string) *CivitAIClient {
 	return &CivitAIClient{
-		token: token,
+		baseURL: defaultCivitAIBaseURL,
+		token:   token,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		downloadClient: &http.Client{},
 	}
 }
 
+// SetTransport sends API and download requests through rt, keeping the
+// retry and header layers around it
+func (c *CivitAIClient) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = withBase(c.httpClient.Transport, rt)
+	c.downloadClient.Transport = withBase(c.downloadClient.Transport, rt)
+}
+
+// SetBaseURL points API requests and download URLs at another server,
+// e.g. a fixture server in place of https://civitai.com
+func (c *CivitAIClient) SetBaseURL(base string) {
+	c.baseURL = strings.TrimSuffix(base, "/")
+}
+
 // SearchModels searches for models on CivitAI
 func (c *CivitAIClient) SearchModels(query string, modelType ModelType) ([]SearchResult, error) {
 	civitType := c.getCivitAIType(modelType)
 
-	searchURL := "https://civitai.com/api/v1/models"
+	searchURL := c.baseURL + "/api/v1/models"
 	params := url.Values{}
 	params.Add("query", query)
 	params.Add("limit", "20")
@@ -92,6 +147,10 @@ func (c *CivitAIClient) SearchModels(query string, modelType ModelType) ([]Searc
 		params.Add("types", civitType)
 	}
 
+	if !c.allowNSFW {
+		params.Add("nsfw", "false")
+	}
+
 	fullURL := fmt.Sprintf("%s?%s", searchURL, params.Encode())
 
 	req, err := http.NewRequest("GET", fullURL, nil)
@@ -99,20 +158,16 @@ func (c *CivitAIClient) SearchModels(query string, modelType ModelType) ([]Searc
 		return nil, err
 	}
 
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
-	}
-	req.Header.Set("Content-Type", "application/json")
+	authorize(req, c.token, c.headers)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.cache.Do(c.httpClient, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("CivitAI API error: %s - %s", resp.Status, string(body))
+		return nil, newHTTPStatusError("CivitAI API error", resp, true)
 	}
 
 	var searchResp CivitAISearchResponse
@@ -122,27 +177,60 @@ func (c *CivitAIClient) SearchModels(query string, modelType ModelType) ([]Searc
 
 	// Convert to SearchResults
 	results := []SearchResult{}
+	candidates, untrusted := 0, 0
 	for _, model := range searchResp.Items {
+		// The nsfw parameter is advisory, so filter here as well
+		if model.NSFW && !c.allowNSFW {
+			continue
+		}
+		if !c.trusted.Allows(model.Creator.Username) {
+			log.Printf("Skipping %s: %s is not in trusted_civitai_creators\n", model.Name, model.Creator.Username)
+			untrusted++
+			continue
+		}
 		for _, version := range model.ModelVersions {
+			var files []SearchResult
 			for _, file := range version.Files {
+				candidates++
 				if c.isValidFile(file) {
-					result := SearchResult{
-						Name:        file.Name,
-						Source:      "civitai",
-						DownloadURL: c.getDownloadURL(file),
-						Hash:        file.Hashes.SHA256,
-						Size:        int64(file.SizeKB * 1024),
-						ModelType:   modelType,
-					}
-					results = append(results, result)
+					result := c.fileResult(file, modelType)
+					result.NSFW = model.NSFW
+					result.Creator = model.Creator.Username
+					result.ModelID = model.ID
+					result.VersionID = version.ID
+					result.DeclaredType = modelTypeFromCivitAI(model.Type)
+					files = append(files, result)
 				}
 			}
+			// A version often ships the same model as SafeTensor and PickleTensor
+			c.policy.Rank(files)
+			results = append(results, files...)
 		}
 	}
 
+	if len(results) == 0 && candidates == 0 && untrusted > 0 {
+		return nil, fmt.Errorf("%w (%d CivitAI models); --allow-untrusted uses them", ErrUntrusted, untrusted)
+	}
+	if len(results) == 0 && candidates > 0 {
+		return nil, fmt.Errorf("%w: %d CivitAI files rejected", ErrAllFiltered, candidates)
+	}
+
 	return results, nil
 }
 
+// fileResult converts a CivitAI file into a SearchResult
+func (c *CivitAIClient) fileResult(file CivitAIModelFile, modelType ModelType) SearchResult {
+	return SearchResult{
+		Name:        file.Name,
+		Source:      "civitai",
+		DownloadURL: c.getDownloadURL(file),
+		Hash:        file.Hashes.SHA256,
+		BLAKE3:      file.Hashes.BLAKE3,
+		Size:        int64(file.SizeKB * 1024),
+		ModelType:   modelType,
+	}
+}
+
 // getCivitAIType converts our model type to CivitAI type
 func (c *CivitAIClient) getCivitAIType(modelType ModelType) string {
 	switch modelType {
@@ -163,6 +251,26 @@ func (c *CivitAIClient) getCivitAIType(modelType ModelType) string {
 	}
 }
 
+// modelTypeFromCivitAI converts a CivitAI type to our model type
+func modelTypeFromCivitAI(civitType string) ModelType {
+	switch strings.ToLower(civitType) {
+	case "checkpoint":
+		return ModelTypeCheckpoint
+	case "lora", "locon", "dora":
+		return ModelTypeLora
+	case "vae":
+		return ModelTypeVAE
+	case "controlnet":
+		return ModelTypeControlNet
+	case "upscaler":
+		return ModelTypeUpscale
+	case "textualinversion":
+		return ModelTypeEmbedding
+	default:
+		return ""
+	}
+}
+
 // isValidFile checks if a file is safe to download
 func (c *CivitAIClient) isValidFile(file CivitAIModelFile) bool {
 	// Check virus scan results
@@ -186,30 +294,63 @@ func (c *CivitAIClient) isValidFile(file CivitAIModelFile) bool {
 		"Other":        true,
 	}
 
-	return validFormats[file.Format]
+	if !validFormats[file.Format] {
+		return false
+	}
+
+	if err := c.policy.Check(file.Name, file.Format == "PickleTensor"); err != nil {
+		log.Printf("Skipping %s: %v\n", file.Name, err)
+		return false
+	}
+
+	return true
 }
 
-// getDownloadURL constructs the download URL for a file
+// getDownloadURL constructs the download URL for a file. A version's
+// download link serves its default variant, so the file's type, format,
+// size and precision are added as query parameters to get the one picked.
 func (c *CivitAIClient) getDownloadURL(file CivitAIModelFile) string {
-	if file.DownloadURL != "" {
-		return file.DownloadURL
+	downloadURL := file.DownloadURL
+	if downloadURL == "" {
+		// Fallback URL construction
+		downloadURL = fmt.Sprintf("%s/api/download/models/%d", c.baseURL, file.ID)
 	}
-	// Fallback URL construction
-	return fmt.Sprintf("https://civitai.com/api/download/models/%d", file.ID)
+
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return downloadURL
+	}
+	format := file.Metadata.Format
+	if format == "" {
+		format = file.Format
+	}
+
+	query := u.Query()
+	for key, value := range map[string]string{
+		"type":   file.Type,
+		"format": format,
+		"size":   file.Metadata.Size,
+		"fp":     file.Metadata.FP,
+	} {
+		// Parameters already in the link win
+		if value != "" && query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
 }
 
 // GetModelByHash searches for a model by its hash
 func (c *CivitAIClient) GetModelByHash(hash string) (*SearchResult, error) {
-	searchURL := fmt.Sprintf("https://civitai.com/api/v1/model-versions/by-hash/%s", hash)
+	searchURL := fmt.Sprintf("%s/api/v1/model-versions/by-hash/%s", c.baseURL, hash)
 
 	req, err := http.NewRequest("GET", searchURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
-	}
+	authorize(req, c.token, c.headers)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -222,7 +363,7 @@ func (c *CivitAIClient) GetModelByHash(hash string) (*SearchResult, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("CivitAI API error: %s", resp.Status)
+		return nil, newHTTPStatusError("CivitAI API error", resp, false)
 	}
 
 	var version CivitAIModelVersion
@@ -230,52 +371,203 @@ func (c *CivitAIClient) GetModelByHash(hash string) (*SearchResult, error) {
 		return nil, err
 	}
 
-	// Find the primary file
+	return c.PrimaryFile(&version, ""), nil
+}
+
+// PrimaryFile returns the main model file of a version, or nil if none is valid
+func (c *CivitAIClient) PrimaryFile(version *CivitAIModelVersion, modelType ModelType) *SearchResult {
+	var files []SearchResult
 	for _, file := range version.Files {
 		if c.isValidFile(file) && file.Type == "Model" {
-			return &SearchResult{
-				Name:        file.Name,
-				Source:      "civitai",
-				DownloadURL: c.getDownloadURL(file),
-				Hash:        file.Hashes.SHA256,
-				Size:        int64(file.SizeKB * 1024),
-			}, nil
+			result := c.fileResult(file, modelType)
+			result.ModelID = version.ModelID
+			result.VersionID = version.ID
+			result.DeclaredType = modelTypeFromCivitAI(version.Model.Type)
+			files = append(files, result)
 		}
 	}
+	if len(files) == 0 {
+		return nil
+	}
 
-	return nil, nil
+	c.policy.Rank(files)
+	return &files[0]
 }
 
-// DownloadFile downloads a file from CivitAI
-func (c *CivitAIClient) DownloadFile(downloadURL, destPath string, onProgress func(downloaded, total int64)) error {
-	req, err := http.NewRequest("GET", downloadURL, nil)
+// GetModel fetches a model and its versions, newest first
+func (c *CivitAIClient) GetModel(modelID int) (*CivitAIModel, error) {
+	modelURL := fmt.Sprintf("%s/api/v1/models/%d", c.baseURL, modelID)
+
+	req, err := http.NewRequest("GET", modelURL, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
-		// CivitAI might require token as query parameter for downloads
-		if !strings.Contains(downloadURL, "token=") {
-			sep := "?"
-			if strings.Contains(downloadURL, "?") {
-				sep = "&"
-			}
-			downloadURL = fmt.Sprintf("%s%stoken=%s", downloadURL, sep, c.token)
-			req, _ = http.NewRequest("GET", downloadURL, nil)
-		}
+	authorize(req, c.token, c.headers)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPStatusError("CivitAI API error", resp, false)
+	}
+
+	var model CivitAIModel
+	if err := json.NewDecoder(resp.Body).Decode(&model); err != nil {
+		return nil, err
+	}
+
+	// Versions listed under a model don't repeat the model ID
+	for i := range model.ModelVersions {
+		model.ModelVersions[i].ModelID = model.ID
+	}
+
+	return &model, nil
+}
+
+// LatestVersionID returns the ID of a model's newest version
+func (c *CivitAIClient) LatestVersionID(modelID int) (int, error) {
+	model, err := c.GetModel(modelID)
+	if err != nil {
+		return 0, err
+	}
+	if len(model.ModelVersions) == 0 {
+		return 0, fmt.Errorf("CivitAI model %d has no versions", modelID)
+	}
+	return model.ModelVersions[0].ID, nil
+}
+
+// GetModelVersion fetches a single model version by its ID
+func (c *CivitAIClient) GetModelVersion(versionID int) (*CivitAIModelVersion, error) {
+	versionURL := fmt.Sprintf("%s/api/v1/model-versions/%d", c.baseURL, versionID)
+
+	req, err := http.NewRequest("GET", versionURL, nil)
+	if err != nil {
+		return nil, err
 	}
 
+	authorize(req, c.token, c.headers)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("download failed: %s - %s", resp.Status, string(body))
+		return nil, newHTTPStatusError("CivitAI API error", resp, false)
+	}
+
+	var version CivitAIModelVersion
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// VersionFiles returns the downloadable files of a model version
+func (c *CivitAIClient) VersionFiles(version *CivitAIModelVersion, modelType ModelType) []SearchResult {
+	if modelType == "" {
+		modelType = modelTypeFromCivitAI(version.Model.Type)
+	}
+
+	results := []SearchResult{}
+	for _, file := range version.Files {
+		if c.isValidFile(file) {
+			result := c.fileResult(file, modelType)
+			result.ModelID = version.ModelID
+			result.VersionID = version.ID
+			result.DeclaredType = modelTypeFromCivitAI(version.Model.Type)
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// DownloadFile downloads a file from CivitAI
+func (c *CivitAIClient) DownloadFile(ctx context.Context, downloadURL, destPath string, sum *streamSum, onProgress func(downloaded, total int64)) error {
+	guard := newStallGuard(ctx, c.stallTimeout)
+	defer guard.Stop()
+	ctx = guard.Context()
+
+	downloadURL, err := c.tokenURL(downloadURL)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+	if err != nil {
+		return err
+	}
+	authorize(req, c.token, c.headers)
+
+	// Fail fast on dead links and learn the size before streaming
+	info, err := headCheck(c.downloadClient, req)
+	if err != nil {
+		return guard.Err(err)
+	}
+
+	check := func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			return newHTTPStatusError("download failed", resp, true)
+		}
+		if err := checkFileResponse(resp); err != nil {
+			// Never leave a web page behind where a model is expected
+			os.Remove(destPath)
+			return err
+		}
+		return nil
+	}
+
+	if chunks := useChunks(c.chunks, info, destPath); chunks > 1 {
+		err = downloadChunked(c.downloadClient, req, destPath, info.Size, chunks, c.syncEvery, sum, guard.Wrap, check, onProgress)
+		return guard.Err(err)
+	}
+	err = streamDownload(c.downloadClient, req, destPath, c.syncEvery, sum, guard.Wrap, check, onProgress)
+	return guard.Err(err)
+}
+
+// tokenURL adds the token to a download URL as the configured query
+// parameter, which CivitAI prefers over the header for downloads. The
+// header is still sent for proxies that only look there.
+func (c *CivitAIClient) tokenURL(downloadURL string) (string, error) {
+	if c.token == "" || c.tokenParam == "" {
+		return downloadURL, nil
+	}
+
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid download URL: %w", err)
+	}
+	query := u.Query()
+	if query.Get(c.tokenParam) != "" {
+		return downloadURL, nil
+	}
+	query.Set(c.tokenParam, c.token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// checkFileResponse rejects responses that are web pages rather than files
+func checkFileResponse(resp *http.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	isText := strings.HasPrefix(mediaType, "text/") || mediaType == "application/json"
+	if !isText {
+		return nil
+	}
+
+	// A text response is only acceptable when explicitly sent as an attachment
+	disposition, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	if mediaType != "text/html" && disposition == "attachment" {
+		return nil
 	}
 
-	return downloadFile(resp.Body, destPath, resp.ContentLength, onProgress)
+	return fmt.Errorf("%w: received %s from %s", ErrRequiresPurchase, contentType, resp.Request.URL.Host)
 }
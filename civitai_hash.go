@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// LookupFileHash returns the SHA256 CivitAI lists for the file a download
+// link serves, for links that came without one, e.g. from model_urls or a
+// model list. The version's file is picked by the link's type, format,
+// size and fp parameters, as CivitAI does, or is its primary file. Links
+// that aren't version download links return "".
+func (c *CivitAIClient) LookupFileHash(downloadURL string) (string, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", err
+	}
+	id, ok := strings.CutPrefix(u.Path, "/api/download/models/")
+	if !ok {
+		return "", nil
+	}
+	versionID, err := strconv.Atoi(strings.TrimSuffix(id, "/"))
+	if err != nil {
+		return "", nil
+	}
+
+	version, err := c.GetModelVersion(versionID)
+	if err != nil {
+		return "", err
+	}
+
+	if file := downloadedFile(version.Files, u.Query()); file != nil {
+		return file.Hashes.SHA256, nil
+	}
+	return "", nil
+}
+
+// downloadedFile returns the file of a version that a download link with
+// the given query serves, or nil when it can't be told
+func downloadedFile(files []CivitAIModelFile, query url.Values) *CivitAIModelFile {
+	var matches []*CivitAIModelFile
+	for i := range files {
+		file := &files[i]
+		format := file.Metadata.Format
+		if format == "" {
+			format = file.Format
+		}
+		if matchesParam(query, "type", file.Type) && matchesParam(query, "format", format) &&
+			matchesParam(query, "size", file.Metadata.Size) && matchesParam(query, "fp", file.Metadata.FP) {
+			matches = append(matches, file)
+		}
+	}
+
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	for _, file := range matches {
+		if file.Primary {
+			return file
+		}
+	}
+	return nil
+}
+
+// matchesParam reports whether a link's query parameter, when present,
+// names value
+func matchesParam(query url.Values, key, value string) bool {
+	want := query.Get(key)
+	return want == "" || strings.EqualFold(want, value)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ComfyUIServer asks a running ComfyUI for the model files it can load,
+// via its /models/<folder> endpoint
+type ComfyUIServer struct {
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	folders map[ModelType]*serverFolder
+}
+
+// serverFolder is the file list ComfyUI returned for one model folder
+type serverFolder struct {
+	ok    bool // false if the server couldn't be asked or lacks the folder
+	names map[string]bool
+	bases map[string]bool // names without their model extension
+}
+
+// NewComfyUIServer returns a client for config.ComfyUIURL, or nil when
+// no server is configured
+func NewComfyUIServer(config *Config) *ComfyUIServer {
+	if config.ComfyUIURL == "" {
+		return nil
+	}
+	return &ComfyUIServer{
+		baseURL: strings.TrimRight(config.ComfyUIURL, "/"),
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: newHeaderTransport(config, "Accept", "application/json"),
+		},
+		folders: make(map[ModelType]*serverFolder),
+	}
+}
+
+// Knows reports whether ComfyUI lists a model. ok is false when the server
+// gave no answer for the model's folder, so callers should fall back to
+// the filesystem.
+func (s *ComfyUIServer) Knows(modelType ModelType, name string) (known, ok bool) {
+	if s == nil {
+		return false, false
+	}
+
+	folder := s.folder(modelType)
+	if !folder.ok {
+		return false, false
+	}
+
+	name = normalizeModelName(name)
+	if folder.names[name] {
+		return true, true
+	}
+	// References without an extension (embeddings) match any model file
+	if !hasModelExtension(name) && folder.bases[name] {
+		return true, true
+	}
+	return false, true
+}
+
+// folder returns the cached file list for a model type, fetching it on
+// first use
+func (s *ComfyUIServer) folder(modelType ModelType) *serverFolder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if folder, ok := s.folders[modelType]; ok {
+		return folder
+	}
+
+	folder := &serverFolder{}
+	names, err := s.listFolder(string(modelType))
+	if err != nil {
+		log.Printf("Cannot list %s from ComfyUI, using the filesystem: %v\n", modelType, err)
+	} else {
+		folder.ok = true
+		folder.names = make(map[string]bool, len(names))
+		folder.bases = make(map[string]bool, len(names))
+		for _, name := range names {
+			name = normalizeModelName(name)
+			folder.names[name] = true
+			folder.bases[strings.TrimSuffix(name, path.Ext(name))] = true
+		}
+	}
+
+	s.folders[modelType] = folder
+	return folder
+}
+
+// listFolder fetches the file names ComfyUI has for one model folder
+func (s *ComfyUIServer) listFolder(folder string) ([]string, error) {
+	resp, err := s.client.Get(s.baseURL + "/models/" + url.PathEscape(folder))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPStatusError("ComfyUI API error", resp, false)
+	}
+
+	var names []string
+	if err := json.NewDecoder(resp.Body).Decode(&names); err != nil {
+		return nil, fmt.Errorf("unexpected /models response: %w", err)
+	}
+	return names, nil
+}
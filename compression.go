@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// compressTransport asks API servers for gzip or deflate and decompresses
+// the response. Go's transport only does this for gzip, and only when the
+// request sets no Accept-Encoding and the transport hasn't disabled it, so
+// a custom Transport would otherwise get large search results uncompressed.
+// Downloads don't use it: model files don't compress and range offsets
+// must refer to the file itself.
+type compressTransport struct {
+	base http.RoundTripper
+}
+
+// newCompressTransport wraps base unless disable_compression is set
+func newCompressTransport(config *Config, base http.RoundTripper) http.RoundTripper {
+	if config.DisableCompression {
+		return base
+	}
+	return &compressTransport{base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *compressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip":
+		body, err = newGzipBody(resp.Body)
+	case "deflate":
+		body = newDeflateBody(resp.Body)
+	default:
+		return resp, nil
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decompressBody reads a decompressed stream and closes the original body
+type decompressBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressor and the response body
+func (b *decompressBody) Close() error {
+	var first error
+	for _, c := range b.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// newGzipBody decompresses a gzip response body. An empty body (e.g. a
+// 304 or HEAD response) is passed through.
+func newGzipBody(body io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	if _, err := buffered.Peek(1); err == io.EOF {
+		return body, nil
+	}
+	zr, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressBody{Reader: zr, closers: []io.Closer{zr, body}}, nil
+}
+
+// newDeflateBody decompresses a deflate response body. HTTP deflate is
+// meant to be zlib-wrapped but some servers send raw deflate, so the zlib
+// header is checked first.
+func newDeflateBody(body io.ReadCloser) io.ReadCloser {
+	buffered := bufio.NewReader(body)
+	if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+		if zr, err := zlib.NewReader(buffered); err == nil {
+			return &decompressBody{Reader: zr, closers: []io.Closer{zr, body}}
+		}
+	}
+	fr := flate.NewReader(buffered)
+	return &decompressBody{Reader: fr, closers: []io.Closer{fr, body}}
+}
+
+// isZlibHeader reports whether two bytes start a zlib stream: the deflate
+// method and a header checksum divisible by 31
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
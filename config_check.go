@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrCheckFailed is returned by CheckSetup when any check failed
+var ErrCheckFailed = errors.New("setup check failed")
+
+// checkTimeout bounds each connectivity check
+const checkTimeout = 15 * time.Second
+
+// Validate reports settings that would make every run fail or misbehave,
+// all at once rather than one per attempt
+func (c *Config) Validate() error {
+	var errs []error
+	if c.ComfyUIPath == "" {
+		errs = append(errs, fmt.Errorf("comfyui_path is empty"))
+	}
+	if c.MaxWorkers < 1 {
+		errs = append(errs, fmt.Errorf("max_workers must be at least 1, got %d", c.MaxWorkers))
+	}
+	if c.Layout != "" {
+		if _, err := layoutDirs(c.Layout); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.RetryAttempts < 1 {
+		errs = append(errs, fmt.Errorf("retry_attempts must be at least 1, got %d", c.RetryAttempts))
+	}
+	for _, key := range sortedKeys(c.ModelPathOverrides) {
+		if !filepath.IsAbs(c.ModelPathOverrides[key]) {
+			errs = append(errs, fmt.Errorf("model_path_overrides[%s] must be an absolute path, got %q",
+				key, c.ModelPathOverrides[key]))
+		}
+	}
+	for _, key := range sortedKeys(c.HeuristicInputs) {
+		if _, err := ParseModelType(c.HeuristicInputs[key]); err != nil {
+			errs = append(errs, fmt.Errorf("heuristic_inputs[%s]: %w", key, err))
+		}
+	}
+	for key, size := range c.MinFileSize {
+		if _, err := ParseSize(size); err != nil {
+			errs = append(errs, fmt.Errorf("min_file_size %s: %w", key, err))
+		}
+	}
+	if err := checkBaseURL(c.HuggingFaceURL); err != nil {
+		errs = append(errs, fmt.Errorf("huggingface_url: %w", err))
+	}
+	if err := checkBaseURL(c.CivitAIURL); err != nil {
+		errs = append(errs, fmt.Errorf("civitai_url: %w", err))
+	}
+	if len(c.PostDownloadHook) > 0 && c.PostDownloadHook[0] == "" {
+		errs = append(errs, fmt.Errorf("post_download_hook has no command"))
+	}
+	if _, err := c.Permissions(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// sortedKeys returns a map's keys in order, so errors about its entries
+// come out the same way every run
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CheckSetup checks the ComfyUI and model directories and that the
+// configured tokens work, printing each result
+func (m *ModelManager) CheckSetup(ctx context.Context) error {
+	failed := false
+	report := func(name string, err error, detail string) {
+		switch {
+		case err != nil:
+			failed = true
+			fmt.Printf("FAIL  %s: %v\n", name, err)
+		case detail != "":
+			fmt.Printf("ok    %s: %s\n", name, detail)
+		default:
+			fmt.Printf("ok    %s\n", name)
+		}
+	}
+
+	// LoadConfig already ran Validate, or we wouldn't be here
+	report("config", nil, "valid")
+
+	if !dirExists(m.config.ComfyUIPath) {
+		report("comfyui_path", fmt.Errorf("%s does not exist", m.config.ComfyUIPath), "")
+	} else {
+		report("comfyui_path", nil, m.config.ComfyUIPath)
+	}
+
+	checked := make(map[string]bool)
+	for _, modelType := range knownModelTypes {
+		dir := m.config.downloadDir(modelType)
+		if checked[dir] {
+			continue
+		}
+		checked[dir] = true
+		detail, err := checkWritableDir(dir)
+		report(string(modelType), err, detail)
+	}
+	if m.config.TempDir != "" {
+		detail, err := checkWritableDir(m.config.TempDir)
+		report("temp_dir", err, detail)
+	}
+
+	if m.config.HuggingFaceToken == "" {
+		fmt.Println("skip  huggingface: no huggingface_token configured")
+	} else {
+		name, err := m.downloader.hfClient.WhoAmI(ctx)
+		report("huggingface", err, "token belongs to "+name)
+	}
+
+	if m.config.CivitAIToken == "" {
+		fmt.Println("skip  civitai: no civitai_token configured")
+	} else {
+		name, err := m.downloader.civitClient.WhoAmI(ctx)
+		report("civitai", err, "token belongs to "+name)
+	}
+
+	if m.scanner.server != nil {
+		report("comfyui_url", m.scanner.server.Ping(ctx), m.config.ComfyUIURL)
+	}
+
+	if failed {
+		return ErrCheckFailed
+	}
+	return nil
+}
+
+// checkWritableDir confirms a directory can be written to by creating a
+// file in it. A missing directory passes if it can be created.
+func checkWritableDir(dir string) (string, error) {
+	existing := dir
+	for !dirExists(existing) {
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", fmt.Errorf("no existing parent of %s", dir)
+		}
+		existing = parent
+	}
+
+	probe, err := os.CreateTemp(existing, ".model-manager-check-*")
+	if err != nil {
+		return "", fmt.Errorf("%s is not writable: %w", existing, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if existing != dir {
+		return dir + " (missing, will be created)", nil
+	}
+	return dir, nil
+}
+
+// WhoAmI returns the account the HuggingFace token belongs to
+func (h *HuggingFaceClient) WhoAmI(ctx context.Context) (string, error) {
+	var user struct {
+		Name string `json:"name"`
+	}
+	if err := whoAmI(ctx, h.httpClient, h.baseURL+"/api/whoami-v2", func(req *http.Request) {
+		authorize(req, h.token, h.headers)
+	}, &user); err != nil {
+		return "", err
+	}
+	return user.Name, nil
+}
+
+// WhoAmI returns the account the CivitAI token belongs to
+func (c *CivitAIClient) WhoAmI(ctx context.Context) (string, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := whoAmI(ctx, c.httpClient, c.baseURL+"/api/v1/me", func(req *http.Request) {
+		authorize(req, c.token, c.headers)
+	}, &user); err != nil {
+		return "", err
+	}
+	if user.Username == "" {
+		return "", fmt.Errorf("token was not accepted")
+	}
+	return user.Username, nil
+}
+
+// whoAmI fetches an account endpoint, bypassing the response cache so
+// the token is really tried
+func whoAmI(ctx context.Context, client *http.Client, endpoint string, auth func(*http.Request), out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	auth(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPStatusError("token check failed", resp, false)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Ping checks that the ComfyUI server answers
+func (s *ComfyUIServer) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPStatusError("ComfyUI check failed", resp, false)
+	}
+	return nil
+}
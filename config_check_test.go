@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateModelPathOverrides(t *testing.T) {
+	config := newTestConfig(t)
+	config.ModelPathOverrides = map[string]string{
+		"checkpoints": "mnt/big/ckpt",
+		"loras":       filepath.Join(t.TempDir(), "loras"),
+	}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted a relative model_path_overrides entry")
+	}
+	if want := "model_path_overrides[checkpoints] must be an absolute path"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Validate = %v, want it to say %q", err, want)
+	}
+	if strings.Contains(err.Error(), "loras") {
+		t.Errorf("Validate rejected the absolute override: %v", err)
+	}
+}
+
+func TestValidateHeuristicInputs(t *testing.T) {
+	config := newTestConfig(t)
+	config.HeuristicInputs = map[string]string{"ipadapter": "clip_vison", "facerestore": "upscale_models"}
+
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "heuristic_inputs[ipadapter]") {
+		t.Fatalf("Validate = %v, want heuristic_inputs[ipadapter] rejected", err)
+	}
+	if strings.Contains(err.Error(), "facerestore") {
+		t.Errorf("Validate rejected a known type: %v", err)
+	}
+}
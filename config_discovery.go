@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// configEnv names the environment variable pointing at the config file
+const configEnv = "COMFY_MODEL_MANAGER_CONFIG"
+
+// configCandidates lists where the config file is looked for when --config
+// isn't given, in order
+func configCandidates() []string {
+	var candidates []string
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "comfy-model-manager", "config.json"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".config", "comfy-model-manager", "config.json"))
+	}
+	return append(candidates, "config.json")
+}
+
+// findConfigPath returns the config file to use without --config:
+// $COMFY_MODEL_MANAGER_CONFIG when set, otherwise the first candidate that
+// exists, falling back to ./config.json so the tool runs on defaults
+func findConfigPath() string {
+	if path := os.Getenv(configEnv); path != "" {
+		return path
+	}
+	for _, path := range configCandidates() {
+		if fileExists(path) {
+			return path
+		}
+	}
+	return "config.json"
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// readDir and statPath are the filesystem calls the cache makes; tests
+// replace them to count how often it touches the disk
+var (
+	readDir  = os.ReadDir
+	statPath = os.Stat
+)
+
+// dirCache caches directory listings for one scan so that probing several
+// candidate names in the same directory costs a single ReadDir instead of
+// one stat per candidate, which matters on network storage
+type dirCache struct {
+	listings map[string]*dirListing // nil entry: listing failed
+	index    map[string][]string    // every model file by name, for --exclude-present-in-any-dir
+}
+
+// dirListing holds one directory's entries, by exact and normalized name
+type dirListing struct {
+	entries map[string]os.DirEntry
+	folded  map[string]string // normalized name -> actual name
+}
+
+// newDirCache creates an empty directory cache
+func newDirCache() *dirCache {
+	return &dirCache{listings: make(map[string]*dirListing)}
+}
+
+// normalizeFileName folds case, surrounding whitespace and Unicode form so
+// that names typed into a workflow match the files on disk
+func normalizeFileName(name string) string {
+	return strings.ToLower(norm.NFC.String(strings.TrimSpace(name)))
+}
+
+// listing returns the entries of dir, reading it on first use
+func (c *dirCache) listing(dir string) *dirListing {
+	if listing, ok := c.listings[dir]; ok {
+		return listing
+	}
+
+	listing := &dirListing{
+		entries: make(map[string]os.DirEntry),
+		folded:  make(map[string]string),
+	}
+
+	dirEntries, err := readDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		c.listings[dir] = nil
+		return nil
+	}
+
+	// A missing directory simply has no entries
+	for _, entry := range dirEntries {
+		listing.entries[entry.Name()] = entry
+		folded := normalizeFileName(entry.Name())
+		if _, taken := listing.folded[folded]; !taken {
+			listing.folded[folded] = entry.Name()
+		}
+	}
+
+	c.listings[dir] = listing
+	return listing
+}
+
+// find resolves path against the cached listing of its directory, matching
+// the file name exactly first and then in normalized form. It returns the
+// actual path on disk and whether it is a directory.
+func (c *dirCache) find(path string) (string, bool, bool) {
+	dir := filepath.Dir(path)
+	listing := c.listing(dir)
+	if listing == nil {
+		// Fall back to a direct stat when the directory can't be listed
+		info, err := statPath(path)
+		if err != nil {
+			return "", false, false
+		}
+		return path, true, info.IsDir()
+	}
+
+	name := filepath.Base(path)
+	entry, found := listing.entries[name]
+	if !found {
+		actual, ok := listing.folded[normalizeFileName(name)]
+		if !ok {
+			return "", false, false
+		}
+		entry = listing.entries[actual]
+		path = filepath.Join(dir, actual)
+	}
+
+	// Resolve symlinks so linked model files and folders are reported correctly
+	if entry.Type()&os.ModeSymlink != 0 {
+		info, err := statPath(path)
+		if err != nil {
+			return "", false, false
+		}
+		return path, true, info.IsDir()
+	}
+
+	return path, true, entry.IsDir()
+}
+
+// findFile resolves path to an existing regular file
+func (c *dirCache) findFile(path string) (string, bool) {
+	actual, exists, isDir := c.find(path)
+	return actual, exists && !isDir
+}
+
+// findDir resolves path to an existing directory
+func (c *dirCache) findDir(path string) (string, bool) {
+	actual, exists, isDir := c.find(path)
+	return actual, exists && isDir
+}
+
+// minFuzzyKey is the shortest name fuzzy matching will try, so short
+// names like "sd" don't match half the library
+const minFuzzyKey = 4
+
+// fuzzyKey reduces a model name to lowercase letters and digits without
+// its extension, e.g. "RealVisXL_V4.0.safetensors" -> "realvisxlv40"
+func fuzzyKey(name string) string {
+	if hasModelExtension(name) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, normalizeFileName(name))
+}
+
+// fuzzyFind finds the model file in path's directory whose name starts
+// with, or else contains, path's name once both are reduced by fuzzyKey.
+// Ambiguous matches are rejected rather than guessed.
+func (c *dirCache) fuzzyFind(path string) (string, bool, error) {
+	key := fuzzyKey(filepath.Base(path))
+	if len(key) < minFuzzyKey {
+		return "", false, nil
+	}
+
+	dir := filepath.Dir(path)
+	listing := c.listing(dir)
+	if listing == nil {
+		return "", false, nil
+	}
+
+	var prefix, contains []string
+	for name, entry := range listing.entries {
+		if entry.IsDir() || !hasModelExtension(name) {
+			continue
+		}
+		switch candidate := fuzzyKey(name); {
+		case strings.HasPrefix(candidate, key):
+			prefix = append(prefix, name)
+		case strings.Contains(candidate, key):
+			contains = append(contains, name)
+		}
+	}
+
+	matches := prefix
+	if len(matches) == 0 {
+		matches = contains
+	}
+	switch len(matches) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return filepath.Join(dir, matches[0]), true, nil
+	default:
+		sort.Strings(matches)
+		return "", false, fmt.Errorf("%q is ambiguous in %s: %s", filepath.Base(path), dir, strings.Join(matches, ", "))
+	}
+}
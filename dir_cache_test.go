@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countFSCalls counts the cache's ReadDir and Stat calls until the test ends
+func countFSCalls(t testing.TB) (reads, stats *int) {
+	reads, stats = new(int), new(int)
+	origRead, origStat := readDir, statPath
+	readDir = func(dir string) ([]os.DirEntry, error) {
+		*reads++
+		return origRead(dir)
+	}
+	statPath = func(path string) (os.FileInfo, error) {
+		*stats++
+		return origStat(path)
+	}
+	t.Cleanup(func() { readDir, statPath = origRead, origStat })
+	return reads, stats
+}
+
+// libraryModels writes n models into the checkpoints directory, half of
+// them present, and returns workflow references to all of them. Names
+// without an extension make every model probe each candidate extension.
+func libraryModels(t testing.TB, config *Config, n int) []Model {
+	dir := config.GetModelDir(ModelTypeCheckpoint)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var models []Model
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("model_%03d", i)
+		if i%2 == 0 {
+			if err := os.WriteFile(filepath.Join(dir, name+".safetensors"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		models = append(models, Model{
+			Name:      name,
+			Type:      ModelTypeCheckpoint,
+			LocalPath: config.GetModelPath(ModelTypeCheckpoint, name),
+		})
+	}
+	return models
+}
+
+func TestCheckModelExistsReadsEachDirOnce(t *testing.T) {
+	config := newTestConfig(t)
+	models := libraryModels(t, config, 50)
+	scanner := NewModelScanner(config)
+
+	reads, stats := countFSCalls(t)
+	present, missing, err := scanner.ScanModels(models)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(present) != 25 || len(missing) != 25 {
+		t.Fatalf("got %d present, %d missing; want 25 each", len(present), len(missing))
+	}
+
+	// Every candidate name lives in the checkpoints directory
+	if *reads != 1 {
+		t.Errorf("ScanModels read directories %d times for 50 models, want 1", *reads)
+	}
+	if *stats != 0 {
+		t.Errorf("ScanModels made %d stat calls, want 0", *stats)
+	}
+}
+
+func BenchmarkCheckModelExists(b *testing.B) {
+	config := DefaultConfig()
+	config.ComfyUIPath = b.TempDir()
+	models := libraryModels(b, config, 200)
+	scanner := NewModelScanner(config)
+	reads, _ := countFSCalls(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache := newDirCache()
+		for j := range models {
+			model := models[j]
+			if _, err := scanner.checkModelExists(&model, cache); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(*reads)/float64(b.N), "readdirs/op")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DirectClient downloads models hosted off-platform: plain HTTPS links
+// ("direct") and Google Drive shares ("gdrive")
+type DirectClient struct {
+	downloadClient *http.Client
+	stallTimeout   time.Duration
+	chunks         int   // parallel range requests per file
+	syncEvery      int64 // bytes between flushes of a partial file
+}
+
+// NewDirectClient creates a client for direct and Google Drive downloads
+func NewDirectClient() *DirectClient {
+	// Drive ties the large-file confirmation to a cookie
+	jar, _ := cookiejar.New(nil)
+	return &DirectClient{downloadClient: &http.Client{Jar: jar}}
+}
+
+// SetTransport carries downloads over rt under the User-Agent header
+func (c *DirectClient) SetTransport(rt http.RoundTripper) {
+	c.downloadClient.Transport = withBase(c.downloadClient.Transport, rt)
+}
+
+// DownloadFile downloads a direct link
+func (c *DirectClient) DownloadFile(ctx context.Context, downloadURL, destPath string, sum *streamSum, onProgress func(downloaded, total int64)) error {
+	guard := newStallGuard(ctx, c.stallTimeout)
+	defer guard.Stop()
+
+	req, err := http.NewRequestWithContext(guard.Context(), "GET", downloadURL, nil)
+	if err != nil {
+		return err
+	}
+
+	// Fail fast on dead links and learn the size before streaming
+	info, err := headCheck(c.downloadClient, req)
+	if err != nil {
+		return guard.Err(err)
+	}
+
+	check := func(resp *http.Response) error {
+		if err := checkDownloadStatus(resp); err != nil {
+			return err
+		}
+		if isHTMLResponse(resp) {
+			return fmt.Errorf("%w: %s", ErrNotAFile, redactedURL(resp.Request.URL))
+		}
+		return nil
+	}
+
+	if chunks := useChunks(c.chunks, info, destPath); chunks > 1 {
+		err = downloadChunked(c.downloadClient, req, destPath, info.Size, chunks, c.syncEvery, sum, guard.Wrap, check, onProgress)
+		return guard.Err(err)
+	}
+	err = streamDownload(c.downloadClient, req, destPath, c.syncEvery, sum, guard.Wrap, check, onProgress)
+	return guard.Err(err)
+}
+
+// DownloadGoogleDrive downloads a Google Drive share, passing the virus
+// scan warning Drive shows instead of the file for large downloads
+func (c *DirectClient) DownloadGoogleDrive(ctx context.Context, shareURL, destPath string, sum *streamSum, onProgress func(downloaded, total int64)) error {
+	id := googleDriveFileID(shareURL)
+	if id == "" {
+		return fmt.Errorf("not a Google Drive file link: %s", shareURL)
+	}
+
+	guard := newStallGuard(ctx, c.stallTimeout)
+	defer guard.Stop()
+
+	downloadURL := "https://drive.google.com/uc?export=download&id=" + url.QueryEscape(id)
+	req, err := http.NewRequestWithContext(guard.Context(), "GET", downloadURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.downloadClient.Do(req)
+	if err != nil {
+		return guard.Err(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPStatusError("download failed", resp, false)
+	}
+
+	if isHTMLResponse(resp) {
+		page, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+		if err != nil {
+			return guard.Err(err)
+		}
+
+		confirmURL := googleDriveConfirmURL(string(page), id)
+		if confirmURL == "" {
+			// No confirmation form: the file is private or over quota
+			return fmt.Errorf("%w: Google Drive did not offer file %s for download", ErrNotAFile, id)
+		}
+
+		req, err = http.NewRequestWithContext(guard.Context(), "GET", confirmURL, nil)
+		if err != nil {
+			return err
+		}
+	}
+	// Request the file again, so a partial download resumes with a Range
+	resp.Body.Close()
+
+	check := func(resp *http.Response) error {
+		if err := checkDownloadStatus(resp); err != nil {
+			return err
+		}
+		if isHTMLResponse(resp) {
+			return fmt.Errorf("%w: Google Drive returned a web page for file %s", ErrNotAFile, id)
+		}
+		return nil
+	}
+	err = streamDownload(c.downloadClient, req, destPath, c.syncEvery, sum, guard.Wrap, check, onProgress)
+	return guard.Err(err)
+}
+
+// isHTMLResponse reports whether a response is a web page
+func isHTMLResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/html"
+}
+
+var (
+	driveFilePath  = regexp.MustCompile(`/file/d/([A-Za-z0-9_-]+)`)
+	driveFormRegex = regexp.MustCompile(`(?s)<form[^>]+id="download-form"[^>]+action="([^"]+)"(.*?)</form>`)
+	driveInput     = regexp.MustCompile(`<input[^>]+name="([^"]+)"[^>]+value="([^"]*)"`)
+	driveConfirm   = regexp.MustCompile(`confirm=([0-9A-Za-z_-]+)`)
+)
+
+// googleDriveFileID extracts the file ID from a Drive share link, e.g.
+// https://drive.google.com/file/d/<id>/view or .../open?id=<id>
+func googleDriveFileID(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !isGoogleDriveHost(u.Host) {
+		return ""
+	}
+	if m := driveFilePath.FindStringSubmatch(u.Path); m != nil {
+		return m[1]
+	}
+	return u.Query().Get("id")
+}
+
+// isGoogleDriveHost reports whether a host serves Google Drive files
+func isGoogleDriveHost(host string) bool {
+	switch host {
+	case "drive.google.com", "docs.google.com", "drive.usercontent.google.com":
+		return true
+	}
+	return false
+}
+
+// googleDriveConfirmURL builds the download URL from Drive's "can't scan
+// this file for viruses" page: a form with hidden inputs on current pages,
+// or a confirm token in a link on older ones
+func googleDriveConfirmURL(page, id string) string {
+	if m := driveFormRegex.FindStringSubmatch(page); m != nil {
+		query := url.Values{}
+		for _, input := range driveInput.FindAllStringSubmatch(m[2], -1) {
+			query.Set(html.UnescapeString(input[1]), html.UnescapeString(input[2]))
+		}
+		return html.UnescapeString(m[1]) + "?" + query.Encode()
+	}
+
+	if m := driveConfirm.FindStringSubmatch(page); m != nil {
+		return fmt.Sprintf("https://drive.google.com/uc?export=download&confirm=%s&id=%s",
+			m[1], url.QueryEscape(id))
+	}
+
+	return ""
+}
+
+// directSource returns "gdrive" for Google Drive links and "direct" for
+// any other http(s) URL
+func directSource(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	if isGoogleDriveHost(strings.ToLower(u.Host)) {
+		return "gdrive"
+	}
+	return "direct"
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errNotSameDevice is returned by renames across filesystems
+var errNotSameDevice error = syscall.EXDEV
+
+// diskSpace is not supported on this platform
+func diskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk space not supported on this platform")
+}
+
+// filesystemID treats every directory as its own filesystem
+func filesystemID(path string) string {
+	return path
+}
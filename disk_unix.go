@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// errNotSameDevice is returned by renames across filesystems
+var errNotSameDevice error = syscall.EXDEV
+
+// diskSpace returns the free and total bytes of the filesystem holding path
+func diskSpace(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
+
+// filesystemID identifies the filesystem holding path, so directories on
+// the same disk are reported once
+func filesystemID(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return path
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprint(st.Dev)
+	}
+	return path
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// errNotSameDevice is ERROR_NOT_SAME_DEVICE, returned by renames across volumes
+var errNotSameDevice error = syscall.Errno(17)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskSpace returns the free and total bytes of the filesystem holding path
+func diskSpace(path string) (free, total uint64, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	ok, _, callErr := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)), uintptr(unsafe.Pointer(&total)), 0)
+	if ok == 0 {
+		return 0, 0, callErr
+	}
+	return free, total, nil
+}
+
+// filesystemID identifies the filesystem holding path, so directories on
+// the same disk are reported once
+func filesystemID(path string) string {
+	return strings.ToUpper(filepath.VolumeName(path))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrNotConfirmed is returned when a large download batch wasn't approved
+var ErrNotConfirmed = errors.New("download not confirmed")
+
+// confirmDownload asks before starting a batch larger than the configured
+// confirm_above_gb. Interactive runs are prompted; others need --yes.
+func (m *ModelManager) confirmDownload(models []Model, results map[string]SearchResult) error {
+	if m.config.ConfirmAboveGB <= 0 || m.assumeYes {
+		return nil
+	}
+
+	var planned []SearchResult
+	var total int64
+	for _, model := range models {
+		if result, ok := results[model.Name]; ok {
+			planned = append(planned, result)
+			total += result.Size
+		}
+	}
+
+	threshold := int64(m.config.ConfirmAboveGB * 1024 * 1024 * 1024)
+	if total <= threshold {
+		return nil
+	}
+
+	sort.Slice(planned, func(i, j int) bool { return planned[i].Size > planned[j].Size })
+	fmt.Printf("\nAbout to download %s, more than the %.0f GB confirmation threshold:\n",
+		formatBytes(total), m.config.ConfirmAboveGB)
+	for _, result := range planned {
+		fmt.Printf("  %10s  %s (%s)\n", formatBytes(result.Size), result.Name, result.Source)
+	}
+
+	if !isInteractive() {
+		return fmt.Errorf("%w: rerun with --yes to download %s", ErrNotConfirmed, formatBytes(total))
+	}
+
+	fmt.Print("Continue? [y/N] ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return nil
+	default:
+		return ErrNotConfirmed
+	}
+}
+
+// isInteractive reports whether stdin is a terminal
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
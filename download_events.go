@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+)
+
+// DownloadEventType identifies the kind of download event
+type DownloadEventType string
+
+const (
+	EventDownloadStarted   DownloadEventType = "started"
+	EventProgressUpdate    DownloadEventType = "progress"
+	EventDownloadCompleted DownloadEventType = "completed"
+	EventDownloadFailed    DownloadEventType = "failed"
+)
+
+// DownloadEvent is emitted by DownloadManager as downloads progress
+type DownloadEvent struct {
+	Type       DownloadEventType
+	Model      Model
+	Downloaded int64
+	Total      int64
+	Speed      float64 // MB/s for this file
+	Err        error   // set for EventDownloadFailed
+}
+
+// DownloadEventHandler receives download events. It is called from worker
+// goroutines, so implementations must be safe for concurrent use.
+type DownloadEventHandler func(DownloadEvent)
+
+// SetEventHandler replaces the default console output with a custom handler
+func (d *DownloadManager) SetEventHandler(handler DownloadEventHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onEvent = handler
+}
+
+// emit sends an event to the configured handler
+func (d *DownloadManager) emit(event DownloadEvent) {
+	d.mu.Lock()
+	handler := d.onEvent
+	d.mu.Unlock()
+
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// printEvent is the default handler that writes progress to stdout
+func (d *DownloadManager) printEvent(event DownloadEvent) {
+	switch event.Type {
+	case EventProgressUpdate:
+		if event.Total > 0 {
+			percent := float64(event.Downloaded) / float64(event.Total) * 100
+			batch := d.GetBatchProgress()
+			fmt.Printf("\r%s: %.1f%% (%.2f MB/s) | %s / %s, %s remaining",
+				event.Model.Name, percent, event.Speed,
+				formatBytes(batch.Downloaded), formatBytes(batch.TotalBytes), formatETA(batch.ETA))
+		}
+	case EventDownloadCompleted:
+		fmt.Println() // New line after progress
+	}
+}
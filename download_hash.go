@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrHashMismatch is returned when a finished download's hash differs
+// from the one its source lists
+var ErrHashMismatch = errors.New("downloaded file does not match the expected hash")
+
+// streamSum hashes a download's bytes as they are written, so verifying
+// the finished file doesn't take a second full read
+type streamSum struct {
+	sha  hash.Hash
+	size int64 // bytes hashed so far
+}
+
+// newStreamSum returns an empty SHA256 stream hash
+func newStreamSum() *streamSum {
+	return &streamSum{sha: sha256.New()}
+}
+
+// resume prepares to hash bytes written from offset on. Reconnects within
+// a download already hashed everything before offset; a partial file left
+// by an earlier run has its first offset bytes read once.
+func (s *streamSum) resume(path string, offset int64) error {
+	if s == nil || s.size == offset {
+		return nil
+	}
+
+	s.sha.Reset()
+	s.size = 0
+	if offset == 0 {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n, err := io.CopyN(s.sha, file, offset)
+	s.size = n
+	return err
+}
+
+// Write adds downloaded bytes to the hash
+func (s *streamSum) Write(p []byte) (int, error) {
+	if s == nil {
+		return len(p), nil
+	}
+	s.size += int64(len(p))
+	return s.sha.Write(p)
+}
+
+// Sum returns the hex SHA256, and whether it covers a file of size bytes
+func (s *streamSum) Sum(size int64) (string, bool) {
+	if s == nil || s.size != size {
+		return "", false
+	}
+	return hex.EncodeToString(s.sha.Sum(nil)), true
+}
+
+// verifyDownload checks a finished download against the source's hashes,
+// removing it on a mismatch so the retry starts over, and returns the
+// file's SHA256 when it is known. The SHA256 comes from the download
+// stream; when the stream couldn't hash all of the file it is read again,
+// with BLAKE3 if the source lists one since that is faster.
+func verifyDownload(path string, result SearchResult, sum *streamSum) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if got, ok := sum.Sum(info.Size()); ok {
+		if result.Hash != "" && !strings.EqualFold(got, result.Hash) {
+			os.Remove(path)
+			return "", fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, result.Hash, got)
+		}
+		return got, nil
+	}
+
+	if err := checkSourceHash(path, result); err != nil {
+		if errors.Is(err, ErrHashMismatch) {
+			os.Remove(path)
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// checkSourceHash checks a file against the hash its source lists,
+// preferring BLAKE3 to SHA256. A source without either passes.
+func checkSourceHash(path string, result SearchResult) error {
+	expected, hashFile := result.BLAKE3, blake3File
+	if expected == "" {
+		expected, hashFile = result.Hash, sha256File
+	}
+	if expected == "" {
+		return nil
+	}
+
+	got, err := hashFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	if !strings.EqualFold(got, expected) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, expected, got)
+	}
+	return nil
+}
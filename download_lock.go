@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when another process is downloading the same model
+var ErrLocked = errors.New("model is being downloaded by another process")
+
+// staleLockAge is how old a lock may get before it is ignored even if its
+// owner still appears to be running (e.g. the PID was reused)
+const staleLockAge = 24 * time.Hour
+
+// downloadLock is an advisory lock file guarding a model's .tmp file
+type downloadLock struct {
+	path string
+}
+
+// acquireDownloadLock creates <localPath>.lock, taking over stale locks left
+// behind by crashed processes
+func acquireDownloadLock(localPath string) (*downloadLock, error) {
+	lockPath := localPath + ".lock"
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			return &downloadLock{path: lockPath}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		pid, stale := inspectLock(lockPath)
+		if !stale {
+			return nil, fmt.Errorf("%w (pid %d)", ErrLocked, pid)
+		}
+
+		// The owner is gone; remove its lock and try once more
+		if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock: %w", err)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: lost race for %s", ErrLocked, lockPath)
+}
+
+// Release removes the lock file
+func (l *downloadLock) Release() {
+	os.Remove(l.path)
+}
+
+// inspectLock reports the owning PID and whether the lock is stale
+func inspectLock(lockPath string) (int, bool) {
+	info, err := os.Stat(lockPath)
+	if err != nil {
+		return 0, true
+	}
+	if time.Since(info.ModTime()) > staleLockAge {
+		return 0, true
+	}
+
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		// Possibly mid-write by another process; treat as held
+		return 0, false
+	}
+
+	return pid, !processAlive(pid)
+}
+
+// processAlive checks whether a process with the given PID exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if pid == os.Getpid() {
+		return true
+	}
+	return processRunning(pid)
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,9 +22,32 @@ type DownloadManager struct {
 	config      *Config
 	hfClient    *HuggingFaceClient
 	civitClient *CivitAIClient
+	direct      *DirectClient
 	workers     int
+	order       string // --order: "", "size" or "type"
+	sourceNames bool   // --use-source-filename
 	mu          sync.Mutex
 	downloads   map[string]*DownloadProgress
+	batchStart  time.Time
+	queuedBytes int64
+	onEvent     DownloadEventHandler
+	// overwrite re-downloads even files whose hash already matches
+	overwrite bool
+	batch     *BatchState // set during DownloadBatch
+	perms     modelPermissions
+	// failStreak counts consecutive failed downloads for the breaker,
+	// which stops the queue once it reaches MaxConsecutiveFailures
+	failStreak int
+	tripped    bool
+	// usedBytes and reservedBytes count the run's downloads against
+	// MaxTotalDownloadBytes: bytes received, and bytes in-flight
+	// downloads are still expected to take
+	usedBytes     int64
+	reservedBytes int64
+	overQuota     bool
+
+	provenanceOnce sync.Once
+	provenance     *Provenance
 }
 
 // DownloadProgress tracks download progress
@@ -25,88 +55,205 @@ type DownloadProgress struct {
 	Model      Model
 	Downloaded int64
 	Total      int64
+	Resumed    int64 // bytes already on disk when the download started
 	StartTime  time.Time
 	Error      error
 	Completed  bool
+	Skipped    bool   // already present with a matching hash
+	Hash       string // SHA256 of the finished download, computed as it streamed
+	reserved   int64  // bytes still held against max_total_download_bytes
+}
+
+// BatchProgress summarizes progress across every queued download
+type BatchProgress struct {
+	Models     map[string]*DownloadProgress
+	TotalBytes int64
+	Downloaded int64
+	Speed      float64 // MB/s across all workers
+	ETA        time.Duration
 }
 
 // DownloadJob represents a download task
 type DownloadJob struct {
 	Model        Model
 	SearchResult SearchResult
+	// Links are the workflow paths linked to the file once it is
+	// downloaded under the source's name, with --use-source-filename
+	Links []string
 }
 
 // NewDownloadManager creates a new download manager
 func NewDownloadManager(config *Config) *DownloadManager {
-	return &DownloadManager{
+	cache := newResponseCache(config.SearchCacheDir(), config.CacheTTL)
+
+	hfClient := NewHuggingFaceClient(config.HuggingFaceToken)
+	hfClient.SetBaseURL(config.hfBaseURL())
+	hfClient.cache = cache
+	hfClient.policy = config.FormatPolicy()
+	hfClient.stallTimeout = config.StallTimeout
+	hfClient.httpClient.Transport = newRetryTransport(config,
+		newCompressTransport(config, newHeaderTransport(config, "Accept", "application/json")))
+	hfClient.downloadClient.Transport = newHeaderTransport(config)
+	hfClient.chunks = config.ChunksPerFile
+	hfClient.syncEvery = config.SyncEvery
+	hfClient.headers = config.SourceHeaders["huggingface"]
+	hfClient.trusted = newTrustList(config.TrustedHFAuthors)
+	if len(hfClient.headers) > 0 {
+		hfClient.httpClient.CheckRedirect = stripOnRedirect(hfClient.headers)
+		hfClient.downloadClient.CheckRedirect = stripOnRedirect(hfClient.headers)
+	}
+
+	civitClient := NewCivitAIClient(config.CivitAIToken)
+	civitClient.SetBaseURL(config.civitAIBaseURL())
+	civitClient.allowNSFW = config.AllowNSFW
+	civitClient.cache = cache
+	civitClient.policy = config.FormatPolicy()
+	civitClient.trusted = newTrustList(config.TrustedCivitAICreators)
+	civitClient.stallTimeout = config.StallTimeout
+	civitClient.httpClient.Transport = newRetryTransport(config,
+		newCompressTransport(config, newHeaderTransport(config, "Accept", "application/json")))
+	civitClient.downloadClient.Transport = newHeaderTransport(config)
+	civitClient.chunks = config.ChunksPerFile
+	civitClient.syncEvery = config.SyncEvery
+	civitClient.headers = config.SourceHeaders["civitai"]
+	civitClient.tokenParam = config.TokenQueryParam
+	if len(civitClient.headers) > 0 {
+		civitClient.httpClient.CheckRedirect = stripOnRedirect(civitClient.headers)
+		civitClient.downloadClient.CheckRedirect = stripOnRedirect(civitClient.headers)
+	}
+
+	direct := NewDirectClient()
+	direct.downloadClient.Transport = newHeaderTransport(config)
+	direct.stallTimeout = config.StallTimeout
+	direct.chunks = config.ChunksPerFile
+	direct.syncEvery = config.SyncEvery
+
+	d := &DownloadManager{
 		config:      config,
-		hfClient:    NewHuggingFaceClient(config.HuggingFaceToken),
-		civitClient: NewCivitAIClient(config.CivitAIToken),
+		hfClient:    hfClient,
+		civitClient: civitClient,
+		direct:      direct,
 		workers:     config.MaxWorkers,
 		downloads:   make(map[string]*DownloadProgress),
 	}
+	d.onEvent = d.printEvent
+
+	// Validate has already rejected invalid permissions
+	d.perms, _ = config.Permissions()
+	return d
 }
 
-// DownloadModels downloads a list of models
-func (d *DownloadManager) DownloadModels(models []Model, searchResults map[string]SearchResult) error {
-	jobs := make(chan DownloadJob, len(models))
-	errors := make(chan error, len(models))
+// DownloadModels downloads a list of models, returning what happened to
+// each. The error summarizes any failures. Cancelling ctx stops the batch,
+// leaving partial .tmp files on disk so they can be resumed.
+func (d *DownloadManager) DownloadModels(ctx context.Context, models []Model, searchResults map[string]SearchResult) (*BatchResult, error) {
+	var queue []DownloadJob
+	for _, model := range orderDownloads(models, searchResults, d.order) {
+		if result, ok := searchResults[model.Name]; ok {
+			model.LocalPath = downloadPath(model, result)
+			queue = append(queue, DownloadJob{
+				Model:        model,
+				SearchResult: result,
+			})
+		}
+	}
+	if d.sourceNames {
+		queue = d.useSourceNames(queue)
+	}
+
+	var queuedBytes int64
+	for _, job := range queue {
+		queuedBytes += job.SearchResult.Size
+	}
+
+	// Start each batch's throughput and ETA afresh, before any worker runs
+	d.mu.Lock()
+	d.downloads = make(map[string]*DownloadProgress)
+	d.batchStart = time.Now()
+	d.queuedBytes = queuedBytes
+	d.failStreak, d.tripped = 0, false
+	d.mu.Unlock()
+
+	jobs := make(chan DownloadJob, len(queue))
+	outcomes := make(chan jobOutcome, len(queue))
 
 	// Start workers
 	var wg sync.WaitGroup
 	for i := 0; i < d.workers; i++ {
 		wg.Add(1)
-		go d.downloadWorker(&wg, jobs, errors)
+		go d.downloadWorker(ctx, &wg, jobs, outcomes)
 	}
 
 	// Queue jobs
-	for _, model := range models {
-		if result, ok := searchResults[model.Name]; ok {
-			jobs <- DownloadJob{
-				Model:        model,
-				SearchResult: result,
-			}
-		}
+	for _, job := range queue {
+		jobs <- job
 	}
 	close(jobs)
 
+	if d.config.ShareProgress {
+		defer d.shareProgress()()
+	}
+
 	// Wait for workers to finish
 	go func() {
 		wg.Wait()
-		close(errors)
+		close(outcomes)
 	}()
 
-	// Collect errors
-	var downloadErrors []error
-	for err := range errors {
-		if err != nil {
-			downloadErrors = append(downloadErrors, err)
-		}
+	result := &BatchResult{}
+	for outcome := range outcomes {
+		result.add(outcome)
 	}
-
-	if len(downloadErrors) > 0 {
-		return fmt.Errorf("download errors: %v", downloadErrors)
+	result.sort()
+
+	switch {
+	case ctx.Err() != nil:
+		return result, fmt.Errorf("downloads interrupted: %w", ctx.Err())
+	case len(result.OverQuota) > 0:
+		return result, fmt.Errorf("%w: %d models left for the next run", ErrQuotaExceeded, len(result.OverQuota))
+	case len(result.NotStarted) > 0:
+		return result, fmt.Errorf("%w: %d failed, %d not started", ErrTooManyFailures, len(result.Failed), len(result.NotStarted))
+	case len(result.Failed) == 1:
+		failed := result.Failed[0]
+		return result, fmt.Errorf("failed to download %s: %w", failed.Model.Name, failed.Err)
+	case len(result.Failed) > 1:
+		return result, fmt.Errorf("%d of %d downloads failed", len(result.Failed), result.Total())
 	}
+	return result, nil
+}
 
-	return nil
+// downloadPath returns where a model is saved. References like
+// "embedding:easynegative" name no extension, so the file gets the one
+// the source has.
+func downloadPath(model Model, result SearchResult) string {
+	if !hasModelExtension(model.LocalPath) && hasModelExtension(result.Name) {
+		return model.LocalPath + path.Ext(result.Name)
+	}
+	return model.LocalPath
 }
 
 // downloadWorker processes download jobs
-func (d *DownloadManager) downloadWorker(wg *sync.WaitGroup, jobs <-chan DownloadJob, errors chan<- error) {
+func (d *DownloadManager) downloadWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan DownloadJob, outcomes chan<- jobOutcome) {
 	defer wg.Done()
 
 	for job := range jobs {
-		err := d.downloadModel(job)
-		if err != nil {
-			errors <- fmt.Errorf("failed to download %s: %w", job.Model.Name, err)
-		} else {
-			errors <- nil
+		if ctx.Err() != nil || d.breakerTripped() {
+			// Drain the queue without starting new downloads
+			outcomes <- jobOutcome{job: job}
+			continue
 		}
+		skipped, err := d.downloadModel(ctx, job)
+		// A model over the download cap is left pending for the next run
+		interrupted := ctx.Err() != nil || errors.Is(err, ErrQuotaExceeded)
+		d.batch.update(job, err, interrupted)
+		d.recordOutcome(err, interrupted)
+		outcomes <- jobOutcome{job: job, err: err, started: true, skipped: skipped}
 	}
 }
 
-// downloadModel downloads a single model with retry logic
-func (d *DownloadManager) downloadModel(job DownloadJob) error {
+// downloadModel downloads a single model with retry logic, reporting
+// whether it was skipped because a verified copy was already there
+func (d *DownloadManager) downloadModel(ctx context.Context, job DownloadJob) (bool, error) {
 	progress := &DownloadProgress{
 		Model:     job.Model,
 		StartTime: time.Now(),
@@ -116,30 +263,181 @@ func (d *DownloadManager) downloadModel(job DownloadJob) error {
 	d.downloads[job.Model.Name] = progress
 	d.mu.Unlock()
 
+	// Model lists, pinned URLs, manifests and --get bypass the filtering
+	// searches do, so every download is held to the format policy here
+	if err := d.checkFormat(job); err != nil {
+		d.setError(progress, err)
+		return false, err
+	}
+
+	job.SearchResult.Hash = d.lookupHash(job)
+
+	// The scanner may have missed a correct file due to a name mismatch
+	if !d.overwrite && d.alreadyVerified(job) {
+		fmt.Printf("%s: already present and verified, skipping\n", job.Model.Name)
+		d.mu.Lock()
+		progress.Completed = true
+		progress.Skipped = true
+		d.mu.Unlock()
+		linkWorkflowNames(job)
+		return true, nil
+	}
+
+	if err := d.reserveQuota(job, progress); err != nil {
+		d.setError(progress, err)
+		return false, err
+	}
+	defer d.releaseQuota(progress)
+
+	d.emit(DownloadEvent{Type: EventDownloadStarted, Model: job.Model, Total: job.SearchResult.Size})
+
+	err := d.downloadWithRetries(ctx, job, progress)
+	if err != nil {
+		d.emit(DownloadEvent{Type: EventDownloadFailed, Model: job.Model, Err: err})
+		return false, err
+	}
+
+	d.mu.Lock()
+	downloaded, total, hash := progress.Downloaded, progress.Total, progress.Hash
+	d.mu.Unlock()
+
+	// Record the hash computed while downloading when the source gave none
+	result := job.SearchResult
+	if result.Hash == "" {
+		result.Hash = hash
+	}
+	if err := d.Provenance().Record(job.Model, result); err != nil {
+		log.Printf("Failed to record provenance for %s: %v\n", job.Model.Name, err)
+	}
+	linkWorkflowNames(job)
+
+	d.emit(DownloadEvent{
+		Type:       EventDownloadCompleted,
+		Model:      job.Model,
+		Downloaded: downloaded,
+		Total:      total,
+	})
+	return false, nil
+}
+
+// checkFormat applies the format policy to the source's file name and the
+// name the file is saved under
+func (d *DownloadManager) checkFormat(job DownloadJob) error {
+	policy := d.config.FormatPolicy()
+	for _, name := range []string{job.SearchResult.Name, filepath.Base(job.Model.LocalPath)} {
+		if err := policy.CheckWeights(name); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrFormatNotAllowed, name, err)
+		}
+	}
+	return nil
+}
+
+// setError records a download's latest error
+func (d *DownloadManager) setError(progress *DownloadProgress, err error) {
+	d.mu.Lock()
+	progress.Error = err
+	d.mu.Unlock()
+}
+
+// Provenance returns the record of downloaded models, loading it on first use
+func (d *DownloadManager) Provenance() *Provenance {
+	d.provenanceOnce.Do(func() {
+		p, err := LoadProvenance(d.config.ProvenanceFile())
+		if err != nil {
+			log.Printf("Ignoring provenance file: %v\n", err)
+		}
+		d.provenance = p
+	})
+	return d.provenance
+}
+
+// lookupHash returns the SHA256 to verify a job's download against. CivitAI
+// lists every file's hash, so links that came without one are looked up
+// once, before any attempt. A failed lookup only costs the verification.
+func (d *DownloadManager) lookupHash(job DownloadJob) string {
+	if job.SearchResult.Source != "civitai" || job.SearchResult.Hash != "" {
+		return job.SearchResult.Hash
+	}
+
+	hash, err := d.civitClient.LookupFileHash(job.SearchResult.DownloadURL)
+	switch {
+	case err != nil:
+		log.Printf("Warning: failed to look up the CivitAI hash of %s, it can't be verified: %v\n",
+			job.Model.Name, redactError(err))
+	case hash == "":
+		log.Printf("Warning: CivitAI lists no SHA256 for %s; it can't be verified\n", job.Model.Name)
+	}
+	return hash
+}
+
+// alreadyVerified checks whether the destination already holds the expected file
+func (d *DownloadManager) alreadyVerified(job DownloadJob) bool {
+	if (job.SearchResult.Hash == "" && job.SearchResult.BLAKE3 == "") || !fileExists(job.Model.LocalPath) {
+		return false
+	}
+	return checkSourceHash(job.Model.LocalPath, job.SearchResult) == nil
+}
+
+// downloadWithRetries runs performDownload until it succeeds or gives up
+func (d *DownloadManager) downloadWithRetries(ctx context.Context, job DownloadJob, progress *DownloadProgress) error {
 	// Ensure directory exists
 	dir := filepath.Dir(job.Model.LocalPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		progress.Error = err
+	if err := d.perms.mkdirAll(dir); err != nil {
+		d.setError(progress, err)
+		return err
+	}
+	if d.config.TempDir != "" {
+		if err := os.MkdirAll(d.config.TempDir, 0755); err != nil {
+			d.setError(progress, err)
+			return err
+		}
+	}
+
+	// The deadline covers every attempt; stalls are caught per attempt
+	if d.config.DownloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.config.DownloadTimeout)
+		defer cancel()
+	}
+
+	// Keep concurrent runs from writing the same .tmp file
+	lock, err := acquireDownloadLock(job.Model.LocalPath)
+	if err != nil {
+		d.setError(progress, err)
 		return err
 	}
+	defer lock.Release()
 
 	// Download with retries
 	var lastErr error
-	for attempt := 0; attempt < d.config.RetryAttempts; attempt++ {
+	attempts := d.retryAttempts(job.Model)
+	for attempt := 0; attempt < attempts; attempt++ {
 		if attempt > 0 {
 			fmt.Printf("Retrying download for %s (attempt %d/%d)\n",
-				job.Model.Name, attempt+1, d.config.RetryAttempts)
-			time.Sleep(time.Second * time.Duration(attempt*2)) // Exponential backoff
+				job.Model.Name, attempt+1, attempts)
+			select {
+			case <-time.After(backoffDelay(attempt, d.config.MaxRetryBackoff)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 
-		err := d.performDownload(job, progress)
+		// Errors are logged and saved, so they must not carry the token
+		err := redactError(d.performDownload(ctx, job, progress))
 		if err == nil {
+			d.mu.Lock()
 			progress.Completed = true
+			d.mu.Unlock()
 			return nil
 		}
 
 		lastErr = err
-		progress.Error = err
+		d.setError(progress, err)
+
+		// Don't retry once the batch has been cancelled or the cap reached
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrQuotaExceeded) || ctx.Err() != nil {
+			break
+		}
 
 		// Don't retry on certain errors
 		if isUnrecoverableError(err) {
@@ -151,112 +449,342 @@ func (d *DownloadManager) downloadModel(job DownloadJob) error {
 }
 
 // performDownload performs the actual download
-func (d *DownloadManager) performDownload(job DownloadJob, progress *DownloadProgress) error {
-	tempPath := job.Model.LocalPath + ".tmp"
+func (d *DownloadManager) performDownload(ctx context.Context, job DownloadJob, progress *DownloadProgress) error {
+	// Reaching the download cap cuts the download short, leaving the
+	// partial file to resume next run
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	tempPath := d.config.PartialPath(job.Model.LocalPath)
 
 	// Check if we can resume a partial download
-	var resumeFrom int64
-	if info, err := os.Stat(tempPath); err == nil {
-		resumeFrom = info.Size()
-		progress.Downloaded = resumeFrom
-	}
+	resumeFrom := partialBytes(tempPath)
+	d.mu.Lock()
+	progress.Downloaded = resumeFrom
+	progress.Resumed = resumeFrom
+	d.mu.Unlock()
 
-	// Progress callback
+	// Progress callback; downloaded counts the partial file's bytes too
+	received := resumeFrom
 	onProgress := func(downloaded, total int64) {
 		d.mu.Lock()
-		progress.Downloaded = downloaded + resumeFrom
+		if downloaded < received {
+			// The server couldn't resume, so the file started over
+			received, resumeFrom, progress.Resumed = 0, 0, 0
+		}
+		if d.countQuota(progress, downloaded-received) {
+			stop(ErrQuotaExceeded)
+		}
+		received = downloaded
+		if job.SearchResult.Size == 0 && progress.Total == 0 && total > 0 {
+			// The search had no size; count the server's for throughput and ETA
+			d.queuedBytes += total
+		}
+		progress.Downloaded = downloaded
 		progress.Total = total
+		current, resumed := progress.Downloaded, resumeFrom
 		d.mu.Unlock()
 
-		// Print progress
-		if total > 0 {
-			percent := float64(progress.Downloaded) / float64(total) * 100
-			speed := calculateSpeed(progress.Downloaded-resumeFrom, time.Since(progress.StartTime))
-			fmt.Printf("\r%s: %.1f%% (%.2f MB/s)", job.Model.Name, percent, speed)
-		}
+		d.emit(DownloadEvent{
+			Type:       EventProgressUpdate,
+			Model:      job.Model,
+			Downloaded: current,
+			Total:      total,
+			Speed:      calculateSpeed(current-resumed, time.Since(progress.StartTime)),
+		})
 	}
 
-	// Download based on source
+	// Download based on source, hashing the file as it streams
 	var err error
+	sum := newStreamSum()
 	switch job.SearchResult.Source {
 	case "huggingface":
-		err = d.hfClient.DownloadFile(job.SearchResult.DownloadURL, tempPath, onProgress)
+		err = d.hfClient.DownloadFile(ctx, job.SearchResult.DownloadURL, tempPath, sum, onProgress)
 	case "civitai":
-		err = d.civitClient.DownloadFile(job.SearchResult.DownloadURL, tempPath, onProgress)
+		err = d.civitClient.DownloadFile(ctx, job.SearchResult.DownloadURL, tempPath, sum, onProgress)
+	case "direct":
+		err = d.direct.DownloadFile(ctx, job.SearchResult.DownloadURL, tempPath, sum, onProgress)
+	case "gdrive":
+		err = d.direct.DownloadGoogleDrive(ctx, job.SearchResult.DownloadURL, tempPath, sum, onProgress)
 	default:
 		err = fmt.Errorf("unknown source: %s", job.SearchResult.Source)
 	}
 
 	if err != nil {
+		if errors.Is(context.Cause(ctx), ErrQuotaExceeded) {
+			return ErrQuotaExceeded
+		}
 		return err
 	}
 
-	fmt.Println() // New line after progress
+	if job.SearchResult.Source == "huggingface" {
+		if err := checkLFSContent(tempPath, job.SearchResult.Size); err != nil {
+			// A short file resumes with a Range; anything else starts over
+			if !errors.Is(err, ErrSizeMismatch) || partialBytes(tempPath) > job.SearchResult.Size {
+				removePartial(tempPath)
+			}
+			return err
+		}
+	}
+
+	if err := checkMinSize(tempPath, job.Model.Type, d.config.minFileSize(job.Model.Type)); err != nil {
+		removePartial(tempPath)
+		return err
+	}
+
+	// Verify before the rename, so a bad file never takes the model's name
+	hash, err := verifyDownload(tempPath, job.SearchResult, sum)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	progress.Hash = hash
+	d.mu.Unlock()
 
 	// Move temp file to final location
-	if err := os.Rename(tempPath, job.Model.LocalPath); err != nil {
+	if err := moveFile(tempPath, job.Model.LocalPath); err != nil {
 		return fmt.Errorf("failed to move downloaded file: %w", err)
 	}
+	if err := d.perms.applyFile(job.Model.LocalPath); err != nil {
+		return err
+	}
 
-	return nil
+	warnTypeMismatch(job)
+	return d.runPostDownloadHook(ctx, job)
+}
+
+// warnTypeMismatch warns when the source or the file itself says the model
+// is a different type than the directory it was installed into
+func warnTypeMismatch(job DownloadJob) {
+	want := job.Model.Type
+	check := func(what string, got ModelType) {
+		if got != "" && !sameModelType(got, want) {
+			log.Printf("Warning: %s was installed as %s but %s says it is %s\n",
+				job.Model.Name, want, what, got)
+		}
+	}
+
+	check("the search result", job.SearchResult.ModelType)
+	check(job.SearchResult.Source, job.SearchResult.DeclaredType)
+
+	if strings.EqualFold(filepath.Ext(job.Model.LocalPath), ".safetensors") {
+		if header, err := ReadSafetensorsHeader(job.Model.LocalPath); err == nil {
+			check("its safetensors metadata", header.DetectType())
+		}
+	}
 }
 
-// GetProgress returns the current download progress
+// GetProgress returns a snapshot of each download's progress. The values
+// are copies, so they can be read while downloads continue.
 func (d *DownloadManager) GetProgress() map[string]*DownloadProgress {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	return d.snapshot()
+}
 
-	// Create a copy to avoid race conditions
-	progressCopy := make(map[string]*DownloadProgress)
+// snapshot copies every download's progress; the caller must hold d.mu
+func (d *DownloadManager) snapshot() map[string]*DownloadProgress {
+	progressCopy := make(map[string]*DownloadProgress, len(d.downloads))
 	for k, v := range d.downloads {
-		progressCopy[k] = v
+		p := *v
+		progressCopy[k] = &p
 	}
-
 	return progressCopy
 }
 
-// downloadFile is a helper function to download a file with progress
-func downloadFile(reader io.Reader, destPath string, totalSize int64, onProgress func(downloaded, total int64)) error {
-	// Create temp file
-	tempPath := destPath + ".tmp"
+// TotalQueuedBytes returns the combined size of all queued downloads
+func (d *DownloadManager) TotalQueuedBytes() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.queuedBytes
+}
 
-	flags := os.O_CREATE | os.O_WRONLY
-	resumeFrom := int64(0)
+// TotalDownloaded returns the bytes downloaded so far across all workers
+func (d *DownloadManager) TotalDownloaded() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.totalDownloaded()
+}
+
+// Throughput returns the combined download speed in MB/s
+func (d *DownloadManager) Throughput() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.throughput()
+}
+
+// ETA estimates the time remaining for the whole batch
+func (d *DownloadManager) ETA() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.eta()
+}
+
+// GetBatchProgress returns per-model progress together with batch totals
+func (d *DownloadManager) GetBatchProgress() BatchProgress {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return BatchProgress{
+		Models:     d.snapshot(),
+		TotalBytes: d.queuedBytes,
+		Downloaded: d.totalDownloaded(),
+		Speed:      d.throughput(),
+		ETA:        d.eta(),
+	}
+}
+
+// totalDownloaded sums downloaded bytes; the caller must hold d.mu
+func (d *DownloadManager) totalDownloaded() int64 {
+	var total int64
+	for _, p := range d.downloads {
+		total += p.Downloaded
+	}
+	return total
+}
+
+// throughput computes batch speed in MB/s; the caller must hold d.mu
+func (d *DownloadManager) throughput() float64 {
+	var fresh int64
+	for _, p := range d.downloads {
+		fresh += p.Downloaded - p.Resumed
+	}
+	return calculateSpeed(fresh, time.Since(d.batchStart))
+}
+
+// eta estimates the remaining batch time; the caller must hold d.mu
+func (d *DownloadManager) eta() time.Duration {
+	remaining := d.queuedBytes - d.totalDownloaded()
+	speed := d.throughput()
+	if remaining <= 0 || speed == 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / (speed * 1024 * 1024) * float64(time.Second))
+}
+
+// maxStreamReconnects bounds how many times in a row a dropped download
+// is reopened without receiving any data
+const maxStreamReconnects = 3
+
+// errStalePartial is returned when the server won't resume a partial
+// file, which is then removed so the download starts over
+var errStalePartial = errors.New("server rejected the partial file's range")
+
+// streamDownload downloads req into destPath, resuming a partial file
+// with a Range request and reopening a dropped connection from where it
+// stopped. Each response is vetted by check, the file is synced every
+// syncEvery bytes (0 only at the end), and sum hashes what is written.
+func streamDownload(client *http.Client, req *http.Request, destPath string, syncEvery int64, sum *streamSum,
+	wrap func(io.Reader) io.Reader, check func(*http.Response) error, onProgress func(downloaded, total int64)) error {
+	streaming, failures, restarted := false, 0, false
+	for {
+		progressed, err := streamOnce(client, req, destPath, syncEvery, sum, wrap, check, onProgress)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errStalePartial) && !restarted {
+			restarted = true
+			continue
+		}
+		if progressed {
+			streaming, failures = true, 0
+		}
 
-	// Check if we can resume
-	if info, err := os.Stat(tempPath); err == nil {
-		resumeFrom = info.Size()
+		// Only reopen streams that were flowing; failing to connect at
+		// all is left to the caller's retries
+		if !streaming || !isConnectionError(err) || req.Context().Err() != nil || failures >= maxStreamReconnects {
+			return err
+		}
+		failures++
+		log.Printf("Connection lost downloading %s (%v), reconnecting\n", redactedURL(req.URL), redactError(err))
+
+		select {
+		case <-time.After(backoffDelay(failures, 10*time.Second)):
+		case <-req.Context().Done():
+			return err
+		}
+	}
+}
+
+// streamOnce makes one request for the rest of destPath and appends the
+// response to it, reporting whether any bytes arrived
+func streamOnce(client *http.Client, base *http.Request, destPath string, syncEvery int64, sum *streamSum,
+	wrap func(io.Reader) io.Reader, check func(*http.Response) error, onProgress func(downloaded, total int64)) (bool, error) {
+	var offset int64
+	if info, err := os.Stat(destPath); err == nil {
+		offset = info.Size()
+	}
+
+	req := base.Clone(base.Context())
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		os.Remove(destPath)
+		return false, errStalePartial
+	}
+	if err := check(resp); err != nil {
+		return false, err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	total := resp.ContentLength
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			os.Remove(destPath)
+			return false, errStalePartial
+		}
 		flags |= os.O_APPEND
-	} else {
+		total = size
+	default:
+		// The server sent the whole file, so start over
+		offset = 0
 		flags |= os.O_TRUNC
 	}
 
-	file, err := os.OpenFile(tempPath, flags, 0644)
+	if err := sum.resume(destPath, offset); err != nil {
+		return false, fmt.Errorf("failed to hash partial file: %w", err)
+	}
+	file, err := os.OpenFile(destPath, flags, 0644)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer file.Close()
 
-	// If resuming, seek to the end
-	if resumeFrom > 0 {
-		if _, err := file.Seek(0, io.SeekEnd); err != nil {
-			return err
-		}
+	if onProgress != nil {
+		onProgress(offset, total)
 	}
 
 	// Download with progress tracking
+	reader := wrap(resp.Body)
 	buf := make([]byte, 1024*1024) // 1MB buffer
-	downloaded := resumeFrom
+	downloaded := offset
+	var unsynced int64
 
 	for {
 		n, err := reader.Read(buf)
 		if n > 0 {
 			if _, err := file.Write(buf[:n]); err != nil {
-				return err
+				return downloaded > offset, err
 			}
+			sum.Write(buf[:n])
 			downloaded += int64(n)
+			if unsynced += int64(n); syncEvery > 0 && unsynced >= syncEvery {
+				if err := file.Sync(); err != nil {
+					return true, err
+				}
+				unsynced = 0
+			}
 			if onProgress != nil {
-				onProgress(downloaded, totalSize)
+				onProgress(downloaded, total)
 			}
 		}
 
@@ -264,18 +792,59 @@ func downloadFile(reader io.Reader, destPath string, totalSize int64, onProgress
 			break
 		}
 		if err != nil {
-			return err
+			// Flush what we have so the partial file can be resumed
+			file.Sync()
+			return downloaded > offset, err
 		}
 	}
 
-	// Close file before renaming
-	file.Close()
+	// A connection closed early looks like EOF, so check the length;
+	// chunked responses report -1 and are skipped
+	if total > 0 && downloaded != total {
+		file.Sync()
+		return downloaded > offset, fmt.Errorf("incomplete download, received %d of %d bytes: %w",
+			downloaded, total, io.ErrUnexpectedEOF)
+	}
 
-	// Move to final location
-	if err := os.Rename(tempPath, destPath); err != nil {
-		return fmt.Errorf("failed to move temp file: %w", err)
+	if err := file.Sync(); err != nil {
+		return true, err
+	}
+	return true, file.Close()
+}
+
+// parseContentRange reads the start offset and full size from a
+// Content-Range header like "bytes 100-999/1000"; the size is -1 when
+// the server gives "*"
+func parseContentRange(header string) (int64, int64, bool) {
+	spec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	span, size, found := strings.Cut(spec, "/")
+	first, _, ok := strings.Cut(span, "-")
+	if !found || !ok {
+		return 0, 0, false
 	}
 
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if size == "*" {
+		return start, -1, true
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// checkDownloadStatus accepts a whole or partial file response
+func checkDownloadStatus(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return newHTTPStatusError("download failed", resp, false)
+	}
 	return nil
 }
 
@@ -287,24 +856,58 @@ func calculateSpeed(bytes int64, duration time.Duration) float64 {
 	return float64(bytes) / (1024 * 1024) / duration.Seconds()
 }
 
+// formatBytes formats a byte count for display, e.g. "23.4GB"
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// formatETA formats a remaining duration for display, e.g. "18 min"
+func formatETA(eta time.Duration) string {
+	switch {
+	case eta <= 0:
+		return "unknown"
+	case eta < time.Minute:
+		return fmt.Sprintf("%d sec", int(eta.Seconds()))
+	case eta < time.Hour:
+		return fmt.Sprintf("%d min", int(eta.Minutes()))
+	default:
+		return fmt.Sprintf("%dh %dm", int(eta.Hours()), int(eta.Minutes())%60)
+	}
+}
+
 // isUnrecoverableError checks if an error should not be retried
 func isUnrecoverableError(err error) bool {
-	// Add checks for specific error types that shouldn't be retried
-	errStr := err.Error()
-	unrecoverableErrors := []string{
-		"404",
-		"403",
-		"401",
-		"not found",
-		"forbidden",
-		"unauthorized",
-	}
-
-	for _, e := range unrecoverableErrors {
-		if strings.Contains(strings.ToLower(errStr), e) {
-			return true
-		}
+	if errors.Is(err, ErrRequiresPurchase) || errors.Is(err, ErrLocked) ||
+		errors.Is(err, ErrDeadLink) || errors.Is(err, ErrNotAFile) ||
+		errors.Is(err, ErrHookFailed) || errors.Is(err, ErrPermissions) ||
+		errors.Is(err, ErrTooSmall) {
+		return true
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Unrecoverable()
 	}
 
 	return false
 }
+
+// isConnectionError reports whether a download failed because the
+// connection dropped, rather than on an HTTP status or a local error
+func isConnectionError(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Resets and aborts come wrapped in a *net.OpError
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
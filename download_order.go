@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// downloadOrders are the queue orders --order accepts; "" keeps the
+// order the models were given in, e.g. the workflow's
+var downloadOrders = []string{"size", "type"}
+
+// typeOrder lists model types from the usually small support files to
+// the multi-gigabyte ones, for the "type" download order
+var typeOrder = []ModelType{
+	ModelTypeVAE,
+	ModelTypeCLIP,
+	ModelTypeTextEncoder,
+	ModelTypeClipVision,
+	ModelTypeEmbedding,
+	ModelTypeLora,
+	ModelTypeUpscale,
+	ModelTypeControlNet,
+	ModelTypeDiffusers,
+	ModelTypeCheckpoint,
+}
+
+// validDownloadOrder checks a --order value
+func validDownloadOrder(order string) error {
+	if order == "" {
+		return nil
+	}
+	for _, known := range downloadOrders {
+		if order == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown download order %q (known orders: %v)", order, downloadOrders)
+}
+
+// orderDownloads returns the models in the order their downloads are
+// queued: smallest first for "size", by typeOrder and then size for
+// "type". Models of unknown size go last.
+func orderDownloads(models []Model, results map[string]SearchResult, order string) []Model {
+	if order == "" {
+		return models
+	}
+
+	rank := make(map[ModelType]int, len(typeOrder))
+	for i, modelType := range typeOrder {
+		rank[modelType] = i
+	}
+	size := func(model Model) int64 {
+		if size := results[model.Name].Size; size > 0 {
+			return size
+		}
+		return 1 << 62
+	}
+
+	ordered := append([]Model(nil), models...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if order == "type" && rank[a.Type] != rank[b.Type] {
+			return rank[a.Type] < rank[b.Type]
+		}
+		return size(a) < size(b)
+	})
+	return ordered
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+var (
+	// ErrDeadLink is returned when a download URL no longer exists
+	ErrDeadLink = errors.New("download link is dead")
+	// ErrNotAFile is returned when a download URL resolves to a web page
+	ErrNotAFile = errors.New("download link serves a web page, not a file")
+)
+
+// headInfo is what a HEAD precheck learned about a download
+type headInfo struct {
+	Size         int64 // -1 when unknown
+	AcceptRanges bool
+}
+
+// headCheck sends a HEAD for a prepared download request so dead links and
+// redirects to web pages fail before any data is streamed. When the server
+// won't answer HEAD the size is unknown and the GET has to decide.
+func headCheck(client *http.Client, req *http.Request) (headInfo, error) {
+	unknown := headInfo{Size: -1}
+
+	head := req.Clone(req.Context())
+	head.Method = http.MethodHead
+
+	resp, err := client.Do(head)
+	if err != nil {
+		if req.Context().Err() != nil {
+			return unknown, err
+		}
+		return unknown, nil
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return unknown, fmt.Errorf("%w: %s returned %s", ErrDeadLink, req.URL.Host, resp.Status)
+	default:
+		// HEAD not allowed, or a presigned URL only signed for GET
+		return unknown, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/html" {
+		return unknown, fmt.Errorf("%w: %s", ErrNotAFile, redactedURL(resp.Request.URL))
+	}
+
+	return headInfo{
+		Size:         resp.ContentLength,
+		AcceptRanges: resp.Header.Get("Accept-Ranges") == "bytes",
+	}, nil
+}
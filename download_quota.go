@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrQuotaExceeded is returned for downloads left for a later run because
+// the run reached MaxTotalDownloadBytes
+var ErrQuotaExceeded = errors.New("max_total_download_bytes reached")
+
+// reserveQuota sets aside the bytes a download still needs, refusing it
+// once the run's cap would be exceeded. Sizes are what's left to fetch,
+// so a resumed partial file only costs its remainder; a download of
+// unknown size reserves nothing and is counted as it arrives.
+func (d *DownloadManager) reserveQuota(job DownloadJob, progress *DownloadProgress) error {
+	limit := d.config.MaxTotalDownloadBytes
+	if limit <= 0 {
+		return nil
+	}
+
+	need := job.SearchResult.Size
+	if info, err := os.Stat(d.config.PartialPath(job.Model.LocalPath)); err == nil {
+		need -= info.Size()
+	}
+	if need < 0 {
+		need = 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.overQuota && d.usedBytes+d.reservedBytes+need > limit {
+		d.overQuota = true
+		fmt.Printf("%s would take the run past max_total_download_bytes (%s); not starting the rest of the queue\n",
+			job.Model.Name, formatBytes(limit))
+	}
+	if d.overQuota {
+		return ErrQuotaExceeded
+	}
+
+	progress.reserved = need
+	d.reservedBytes += need
+	return nil
+}
+
+// releaseQuota returns what a finished download reserved but didn't use
+func (d *DownloadManager) releaseQuota(progress *DownloadProgress) {
+	d.mu.Lock()
+	d.reservedBytes -= progress.reserved
+	progress.reserved = 0
+	d.mu.Unlock()
+}
+
+// countQuota adds newly received bytes to the run's total, reporting
+// whether it has gone over the cap. The caller must hold d.mu.
+func (d *DownloadManager) countQuota(progress *DownloadProgress, n int64) bool {
+	d.usedBytes += n
+	used := min(n, progress.reserved)
+	progress.reserved -= used
+	d.reservedBytes -= used
+
+	if limit := d.config.MaxTotalDownloadBytes; limit > 0 && d.usedBytes > limit {
+		if !d.overQuota {
+			d.overQuota = true
+			fmt.Printf("Reached max_total_download_bytes (%s); stopping, partial files resume next run\n",
+				formatBytes(limit))
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTooSmall is returned when a finished download is smaller than the
+// minimum configured for its model type
+var ErrTooSmall = errors.New("downloaded file is too small to be the model")
+
+// minFileSize returns the smallest file accepted as a model of this type,
+// or 0 when any size is; Validate has already checked the sizes parse
+func (c *Config) minFileSize(modelType ModelType) int64 {
+	size, ok := c.MinFileSize[string(modelType)]
+	if !ok {
+		size = c.MinFileSize["default"]
+	}
+	if size == "" {
+		return 0
+	}
+	n, _ := ParseSize(size)
+	return n
+}
+
+// checkMinSize rejects a download smaller than minSize, saying what the
+// file looks like when its start gives it away. Only weight files are
+// checked, so the configs of a diffusers repo aren't held to a minimum.
+func checkMinSize(path string, modelType ModelType, minSize int64) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if minSize <= 0 || !hasModelExtension(path) || ext == ".json" || ext == ".yaml" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() >= minSize {
+		return nil
+	}
+
+	looks := "truncated or not a model"
+	if kind := sniffNonModel(path); kind != "" {
+		looks = kind
+	}
+	return fmt.Errorf("%w: %s is %s, under the min_file_size of %s for %s",
+		ErrTooSmall, formatBytes(info.Size()), looks, formatBytes(minSize), modelType)
+}
+
+// sniffNonModel names what a small download really is when it is an HTML
+// page, JSON error or Git LFS pointer, or returns ""
+func sniffNonModel(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	head = bytes.ToLower(bytes.TrimSpace(head[:n]))
+
+	switch {
+	case bytes.HasPrefix(head, []byte(lfsPointerPrefix)):
+		return "a Git LFS pointer"
+	case bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html")):
+		return "an HTML page"
+	case bytes.HasPrefix(head, []byte("{")) && bytes.Contains(head, []byte(`"error`)):
+		return "a JSON error response"
+	}
+	return ""
+}
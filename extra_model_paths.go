@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadExtraModelPaths parses ComfyUI's extra_model_paths.yaml and returns
+// the absolute directories it configures for each known model type
+func LoadExtraModelPaths(path string) (map[ModelType][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extra model paths: %w", err)
+	}
+
+	// Each top-level section is a base_path plus per-folder entries
+	var sections map[string]map[string]interface{}
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("failed to parse extra model paths: %w", err)
+	}
+
+	// ComfyUI resolves relative base paths against the yaml file's directory
+	yamlDir := filepath.Dir(path)
+
+	dirs := make(map[ModelType][]string)
+	for _, section := range sections {
+		basePath := yamlDir
+		if base, ok := section["base_path"].(string); ok && base != "" {
+			basePath = expandPath(base, yamlDir)
+		}
+
+		for key, value := range section {
+			modelType, err := ParseModelType(key)
+			if err != nil {
+				continue // base_path, is_default, configs, hypernetworks, ...
+			}
+
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			// Multiple folders are given as a multi-line string
+			for _, line := range strings.Split(text, "\n") {
+				line = strings.TrimSpace(line)
+				if line == "" {
+					continue
+				}
+				dirs[modelType] = append(dirs[modelType], expandPath(line, basePath))
+			}
+		}
+	}
+
+	return dirs, nil
+}
+
+// expandPath expands ~ and roots a relative path at base
+func expandPath(path, base string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
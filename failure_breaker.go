@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ErrTooManyFailures is returned when a batch stops early because
+// MaxConsecutiveFailures downloads in a row failed
+var ErrTooManyFailures = errors.New("too many consecutive download failures")
+
+// retryAttempts returns how many times a model is tried: its own Retries,
+// then model_retries by name or file name, then retry_attempts
+func (d *DownloadManager) retryAttempts(model Model) int {
+	if model.Retries > 0 {
+		return model.Retries
+	}
+	if n, ok := d.config.ModelRetries[model.Name]; ok && n > 0 {
+		return n
+	}
+	if n, ok := d.config.ModelRetries[path.Base(normalizeModelName(model.Name))]; ok && n > 0 {
+		return n
+	}
+	return d.config.RetryAttempts
+}
+
+// recordOutcome updates the failure streak after a download finishes,
+// tripping the breaker when it reaches MaxConsecutiveFailures.
+// Interrupted downloads say nothing about the sources and are ignored.
+func (d *DownloadManager) recordOutcome(err error, interrupted bool) {
+	if interrupted {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err == nil {
+		d.failStreak = 0
+		return
+	}
+
+	d.failStreak++
+	if limit := d.config.MaxConsecutiveFailures; limit > 0 && d.failStreak >= limit && !d.tripped {
+		d.tripped = true
+		fmt.Printf("%d downloads failed in a row (max_consecutive_failures); not starting the rest of the queue\n",
+			d.failStreak)
+	}
+}
+
+// breakerTripped reports whether the queue has been stopped by failures
+func (d *DownloadManager) breakerTripped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.tripped
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fixtureRepo is the one HuggingFace repository a fixture server has
+const fixtureRepo = "fixtures/models"
+
+// fixtureServer serves the files of a directory the way HuggingFace and
+// CivitAI would, so workflows can be processed end to end without the
+// network: every HuggingFace search finds fixtureRepo holding the files,
+// and CivitAI finds nothing
+type fixtureServer struct {
+	dir   string
+	files []HFRepoFile
+}
+
+// newFixtureServer indexes the files under dir, with their sizes and
+// hashes so downloads are verified as real ones are, and starts serving
+func newFixtureServer(dir string) (*httptest.Server, error) {
+	f := &fixtureServer{dir: dir}
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := sha256File(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+
+		file := HFRepoFile{Type: "file", Path: filepath.ToSlash(rel), Size: info.Size()}
+		file.LFS = &struct {
+			Size        int64  `json:"size"`
+			SHA256      string `json:"sha256"`
+			PointerSize int    `json:"pointerSize"`
+		}{Size: info.Size(), SHA256: hash}
+		f.files = append(f.files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index fixtures: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/models", func(w http.ResponseWriter, r *http.Request) {
+		writeFixtureJSON(w, []HFModel{{ID: fixtureRepo, Author: "fixtures"}})
+	})
+	mux.HandleFunc("GET /api/models/"+fixtureRepo+"/tree/{path...}", f.serveTree)
+	mux.HandleFunc("GET /"+fixtureRepo+"/resolve/{path...}", f.serveFile)
+	mux.HandleFunc("GET /api/whoami-v2", func(w http.ResponseWriter, r *http.Request) {
+		writeFixtureJSON(w, map[string]string{"name": "fixtures"})
+	})
+	mux.HandleFunc("GET /api/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		writeFixtureJSON(w, map[string]string{"username": "fixtures"})
+	})
+	mux.HandleFunc("GET /api/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		writeFixtureJSON(w, CivitAISearchResponse{Items: []CivitAIModel{}})
+	})
+
+	return httptest.NewServer(mux), nil
+}
+
+// serveTree lists the fixtures under the path after the revision
+func (f *fixtureServer) serveTree(w http.ResponseWriter, r *http.Request) {
+	_, prefix, _ := strings.Cut(r.PathValue("path"), "/")
+	if prefix != "" {
+		prefix = strings.Trim(prefix, "/") + "/"
+	}
+
+	files := []HFRepoFile{}
+	for _, file := range f.files {
+		if strings.HasPrefix(file.Path, prefix) {
+			files = append(files, file)
+		}
+	}
+	writeFixtureJSON(w, files)
+}
+
+// serveFile serves a fixture at any revision, with range support so
+// resumed and chunked downloads work
+func (f *fixtureServer) serveFile(w http.ResponseWriter, r *http.Request) {
+	_, name, _ := strings.Cut(r.PathValue("path"), "/")
+	for _, file := range f.files {
+		if file.Path != name {
+			continue
+		}
+		content, err := os.Open(filepath.Join(f.dir, filepath.FromSlash(name)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, name, time.Time{}, content)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// writeFixtureJSON writes v as a JSON response
+func writeFixtureJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// UseFixtureServer serves the model files in dir from a local fixture
+// server and points the HuggingFace and CivitAI clients at it, for
+// end-to-end runs without the network. Searches need a token, so a
+// placeholder is set, and min_file_size is dropped since fixtures are
+// small. The returned function stops the server.
+func (m *ModelManager) UseFixtureServer(dir string) (func(), error) {
+	server, err := newFixtureServer(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m.downloader.hfClient.SetBaseURL(server.URL)
+	m.downloader.civitClient.SetBaseURL(server.URL)
+	if m.config.HuggingFaceToken == "" {
+		m.config.HuggingFaceToken = "fixture"
+	}
+	if m.config.CivitAIToken == "" {
+		m.config.CivitAIToken = "fixture"
+	}
+	m.config.MinFileSize = nil
+
+	fmt.Printf("Serving fixtures from %s at %s\n", dir, server.URL)
+	return server.Close, nil
+}
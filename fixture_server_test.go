@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessWorkflowWithFixtures(t *testing.T) {
+	config := newTestConfig(t)
+	manager := newTestManager(config)
+
+	fixtures := map[string][]byte{
+		"sd_xl_base_1.0.safetensors":   bytes.Repeat([]byte("checkpoint"), 1000),
+		"loras/add_detail.safetensors": bytes.Repeat([]byte("lora"), 1000),
+	}
+	fixtureDir := t.TempDir()
+	for name, content := range fixtures {
+		writeFile(t, filepath.Join(fixtureDir, filepath.FromSlash(name)), content)
+	}
+	stop, err := manager.UseFixtureServer(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	workflowPath := filepath.Join(t.TempDir(), "workflow.json")
+	writeFile(t, workflowPath, []byte(`{
+		"1": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "sd_xl_base_1.0.safetensors"}},
+		"2": {"class_type": "LoraLoader", "inputs": {"lora_name": "add_detail.safetensors", "model": ["1", 0], "clip": ["1", 1]}}
+	}`))
+
+	if err := manager.ProcessWorkflow(context.Background(), workflowPath); err != nil {
+		t.Fatal(err)
+	}
+
+	installed := map[string][]byte{
+		config.GetModelPath(ModelTypeCheckpoint, "sd_xl_base_1.0.safetensors"): fixtures["sd_xl_base_1.0.safetensors"],
+		config.GetModelPath(ModelTypeLora, "add_detail.safetensors"):           fixtures["loras/add_detail.safetensors"],
+	}
+	for path, want := range installed {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("model not installed: %v", err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from its fixture", path)
+		}
+	}
+
+	// A second run finds everything present
+	models, err := manager.parser.ParseWorkflow(workflowPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, missing, err := manager.scanner.ScanModels(models); err != nil || len(missing) != 0 {
+		t.Errorf("after processing, %d models still missing (%v)", len(missing), err)
+	}
+}
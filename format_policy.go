@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrFormatNotAllowed is returned when a download's format is rejected by
+// allowed_formats, blocked_formats or allow_pickle
+var ErrFormatNotAllowed = errors.New("format not allowed")
+
+// pickleExtensions are formats that can execute code when loaded
+var pickleExtensions = map[string]bool{
+	".ckpt": true,
+	".pt":   true,
+	".pth":  true,
+	".bin":  true,
+}
+
+// FormatPolicy decides which file formats may be downloaded
+type FormatPolicy struct {
+	Allowed     []string // extensions; empty allows everything not blocked
+	Blocked     []string // extensions
+	AllowPickle bool
+	Prefer      string // extension ranked first when a model has several formats
+}
+
+// FormatPolicy returns the configured download format policy
+func (c *Config) FormatPolicy() FormatPolicy {
+	return FormatPolicy{
+		Allowed:     c.AllowedFormats,
+		Blocked:     c.BlockedFormats,
+		AllowPickle: c.AllowPickle,
+		Prefer:      c.PreferFormat,
+	}
+}
+
+// Check returns nil if the file may be downloaded, or the reason it may not.
+// pickle marks files known to be pickled regardless of their extension.
+func (p FormatPolicy) Check(filename string, pickle bool) error {
+	ext := strings.ToLower(path.Ext(filename))
+
+	if !p.AllowPickle && (pickle || pickleExtensions[ext]) {
+		return fmt.Errorf("pickle format %s is not allowed", ext)
+	}
+
+	for _, blocked := range p.Blocked {
+		if normalizeExt(blocked) == ext {
+			return fmt.Errorf("format %s is blocked", ext)
+		}
+	}
+
+	if len(p.Allowed) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Allowed {
+		if normalizeExt(allowed) == ext {
+			return nil
+		}
+	}
+	return fmt.Errorf("format %s is not in allowed_formats", ext)
+}
+
+// CheckWeights applies Check to weight files only, passing configs and
+// other repository files such as tokenizer vocabularies
+func (p FormatPolicy) CheckWeights(filename string) error {
+	ext := strings.ToLower(path.Ext(filename))
+	if !hasModelExtension(filename) || ext == ".json" || ext == ".yaml" {
+		return nil
+	}
+	return p.Check(filename, false)
+}
+
+// Rank orders the files of one model by format: the preferred extension
+// first and pickle formats last, otherwise keeping their order
+func (p FormatPolicy) Rank(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return p.rank(results[i].Name) < p.rank(results[j].Name)
+	})
+}
+
+// rank scores a file name for Rank; lower is better
+func (p FormatPolicy) rank(filename string) int {
+	ext := strings.ToLower(path.Ext(filename))
+	switch {
+	case p.Prefer != "" && ext == normalizeExt(p.Prefer):
+		return 0
+	case pickleExtensions[ext]:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// normalizeExt lowercases an extension and ensures it has a leading dot
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
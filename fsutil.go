@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to a temp file in the same directory, fsyncs
+// it and renames it over path, so a crash never leaves a truncated file.
+// An existing file's mode is preserved; new files get perm.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Clean up the temp file on any failure before the rename
+	ok := false
+	defer func() {
+		if !ok {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	ok = true
+
+	// Persist the rename itself; not supported on every platform
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// PartialPath returns where a download to localPath is written until it
+// completes: next to it, or in TempDir when one is configured. The name
+// is stable so an interrupted download resumes on the next run.
+func (c *Config) PartialPath(localPath string) string {
+	if c.TempDir == "" {
+		return localPath + ".tmp"
+	}
+	sum := sha256.Sum256([]byte(localPath))
+	return filepath.Join(c.TempDir, hex.EncodeToString(sum[:6])+"-"+filepath.Base(localPath)+".tmp")
+}
+
+// moveFile renames src to dst, copying instead when they are on different
+// filesystems. The copy goes to a temp file beside dst that is renamed into
+// place, so dst never holds a partial file.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	// Keep src on failure so the download can be resumed or retried
+	if err := copyFileAtomic(src, dst); err != nil {
+		return fmt.Errorf("failed to copy across filesystems: %w", err)
+	}
+	if err := os.Remove(src); err != nil {
+		log.Printf("Copied %s but could not remove it: %v\n", src, err)
+	}
+	return nil
+}
+
+// isCrossDevice reports whether a rename failed because the paths are on
+// different filesystems
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errNotSameDevice)
+}
+
+// copyFileAtomic copies src over dst through a synced temp file in dst's
+// directory
+func copyFileAtomic(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	ok := false
+	defer func() {
+		if !ok {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return err
+	}
+	ok = true
+
+	if d, err := os.Open(filepath.Dir(dst)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -1,3 +1,11 @@
 module github.com/niuguy/comfyui-model-manager
 
 go 1.24.5
+
+require (
+	golang.org/x/text v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
+	lukechampine.com/blake3 v1.4.1
+)
+
+require github.com/klauspost/cpuid/v2 v2.0.9 // indirect
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// trainingArtifacts are files training runs leave next to the weights in
+// a repository: optimizer, scheduler and RNG states and the arguments
+var trainingArtifacts = []string{"optimizer", "scheduler", "scaler", "rng_state", "training_args", "training_state"}
+
+// isTrainingArtifact reports whether a repository file is training state
+// rather than weights, including anything under an intermediate
+// checkpoint-<step> folder
+func isTrainingArtifact(filename string) bool {
+	parts := strings.Split(strings.ToLower(filename), "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if step, ok := strings.CutPrefix(dir, "checkpoint-"); ok && step != "" && strings.Trim(step, "0123456789") == "" {
+			return true
+		}
+	}
+
+	// Only weight-like files; configs such as scheduler_config.json are
+	// part of diffusers models
+	base := parts[len(parts)-1]
+	ext := path.Ext(base)
+	if !hasModelExtension(base) || ext == ".json" || ext == ".yaml" {
+		return false
+	}
+	stem := strings.TrimSuffix(base, ext)
+	for _, artifact := range trainingArtifacts {
+		if stem == artifact || strings.HasPrefix(stem, artifact+"_") || strings.HasPrefix(stem, artifact+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFilePattern restricts the repository files searches and --get pick
+// from to those matching a glob, e.g. "*fp16*.safetensors". A pattern
+// without a slash matches the file name, one with a slash the whole path.
+func (h *HuggingFaceClient) SetFilePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+	}
+	h.filePattern = pattern
+	return nil
+}
+
+// matchesFilePattern reports whether a repository file passes the file
+// pattern, if one is set
+func (h *HuggingFaceClient) matchesFilePattern(filename string) bool {
+	if h.filePattern == "" {
+		return true
+	}
+	name := path.Base(filename)
+	if strings.Contains(h.filePattern, "/") {
+		name = filename
+	}
+	ok, _ := path.Match(strings.ToLower(h.filePattern), strings.ToLower(name))
+	return ok
+}
+
+// matchRequestedFile narrows a repository's files to those named like the
+// requested model, so a repository of variants resolves to the file the
+// workflow asked for. Without an exact match every file is kept, to be
+// ranked as before.
+func matchRequestedFile(name string, results []SearchResult) []SearchResult {
+	requested := strings.ToLower(path.Base(normalizeModelName(name)))
+	withExt := hasModelExtension(requested)
+
+	var matches []SearchResult
+	for _, result := range results {
+		file := strings.ToLower(path.Base(result.Name))
+		if !withExt {
+			file = strings.TrimSuffix(file, path.Ext(file))
+		}
+		if file == requested {
+			matches = append(matches, result)
+		}
+	}
+
+	if len(matches) == 0 {
+		return results
+	}
+	return matches
+}
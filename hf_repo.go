@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ListRepoFiles lists every file of a repository revision, or only those
+// under prefix, for downloading a multi-file model such as a diffusers
+// folder. Paths are relative to the repository root.
+func (h *HuggingFaceClient) ListRepoFiles(repoID, revision, prefix string) ([]SearchResult, error) {
+	if revision == "" {
+		revision = "main"
+	}
+	treeURL := fmt.Sprintf("%s/api/models/%s/tree/%s", h.baseURL, repoID, url.PathEscape(revision))
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		treeURL += "/" + prefix
+	}
+	treeURL += "?recursive=true"
+
+	var files []HFRepoFile
+	for treeURL != "" {
+		page, next, err := h.getTreePage(treeURL)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page...)
+		treeURL = next
+	}
+
+	// A pickle weight next to a safetensors copy of itself is redundant
+	stems := make(map[string]bool)
+	for _, file := range files {
+		if strings.EqualFold(path.Ext(file.Filename()), ".safetensors") {
+			stems[strings.TrimSuffix(file.Filename(), path.Ext(file.Filename()))] = true
+		}
+	}
+
+	var results []SearchResult
+	for _, file := range files {
+		name := file.Filename()
+		if file.Type == "directory" || path.Base(name) == ".gitattributes" || isTrainingArtifact(name) {
+			continue
+		}
+
+		ext := strings.ToLower(path.Ext(name))
+		if pickleExtensions[ext] && stems[strings.TrimSuffix(name, path.Ext(name))] {
+			continue
+		}
+		if err := h.policy.CheckWeights(name); err != nil {
+			log.Printf("Skipping %s/%s: %v\n", repoID, name, err)
+			continue
+		}
+
+		result := SearchResult{
+			Name:        name,
+			Source:      "huggingface",
+			DownloadURL: h.resolveURL(repoID, revision, name),
+			Size:        file.Size,
+		}
+		if file.LFS != nil {
+			result.Size = file.LFS.Size
+			result.Hash = file.LFS.SHA256
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// getTreePage fetches one page of a tree listing, returning the URL of
+// the next page from the Link header, if any
+func (h *HuggingFaceClient) getTreePage(treeURL string) ([]HFRepoFile, string, error) {
+	req, err := http.NewRequest("GET", treeURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	authorize(req, h.token, h.headers)
+
+	resp, err := h.cache.Do(h.httpClient, req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", newHTTPStatusError("failed to list repository files", resp, false)
+	}
+
+	var files []HFRepoFile
+	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		return nil, "", err
+	}
+
+	return files, nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink returns the rel="next" URL of a Link header
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// GetRepo downloads every file of a HuggingFace repository, or of the
+// subtree the spec names, keeping its layout under <type dir>/<repo name>.
+// The type defaults to diffusers.
+func (m *ModelManager) GetRepo(ctx context.Context, spec string, modelType ModelType) error {
+	parsed, err := ParseModelSpec(spec)
+	if err != nil {
+		return err
+	}
+	if parsed.Source != "huggingface" {
+		return fmt.Errorf("--all-files needs a HuggingFace repository, got %s", parsed.Source)
+	}
+	if modelType == "" {
+		modelType = ModelTypeDiffusers
+	}
+
+	files, err := m.downloader.hfClient.ListRepoFiles(parsed.RepoID, parsed.Revision, parsed.Filename)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", parsed.RepoID, err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files found in %s", path.Join(parsed.RepoID, parsed.Filename))
+	}
+
+	repoName := path.Base(parsed.RepoID)
+	var missing []Model
+	results := make(map[string]SearchResult)
+	for _, file := range files {
+		file.ModelType = modelType
+		model := Model{
+			Name: path.Join(repoName, file.Name),
+			Type: modelType,
+			Size: file.Size,
+		}
+		model.LocalPath = m.config.GetModelPath(modelType, model.Name)
+
+		if fileExists(model.LocalPath) && !m.force {
+			continue
+		}
+		missing = append(missing, model)
+		results[model.Name] = file
+	}
+
+	dir := m.config.GetModelPath(modelType, repoName)
+	if len(missing) == 0 {
+		fmt.Printf("All %d files of %s are already in %s\n", len(files), parsed.RepoID, dir)
+		return nil
+	}
+
+	if err := m.confirmDownload(missing, results); err != nil {
+		return err
+	}
+
+	fmt.Printf("Downloading %d of %d files from %s to %s\n", len(missing), len(files), parsed.RepoID, dir)
+	return m.downloadAndSummarize(ctx, missing, results)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBody bounds how much of an error response is kept for messages
+const maxErrorBody = 512
+
+// HTTPStatusError is returned when a server answers with an unexpected status
+type HTTPStatusError struct {
+	Op     string // what failed, e.g. "CivitAI API error"
+	Code   int
+	Status string
+	Body   string // start of the response body, if kept
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("%s: %s - %s", e.Op, e.Status, e.Body)
+	}
+	return fmt.Sprintf("%s: %s", e.Op, e.Status)
+}
+
+// Unrecoverable reports whether retrying the request can't help: the
+// resource is gone or access is denied. Rate limits and server errors
+// are worth retrying.
+func (e *HTTPStatusError) Unrecoverable() bool {
+	switch {
+	case e.Code == http.StatusTooManyRequests, e.Code == http.StatusRequestTimeout:
+		return false
+	case e.Code >= 500:
+		return false
+	default:
+		return e.Code >= 400
+	}
+}
+
+// newHTTPStatusError builds an HTTPStatusError from a response, optionally
+// keeping the start of its body
+func newHTTPStatusError(op string, resp *http.Response, keepBody bool) *HTTPStatusError {
+	err := &HTTPStatusError{Op: op, Code: resp.StatusCode, Status: resp.Status}
+	if keepBody {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		err.Body = string(body)
+	}
+	return err
+}
+
+// redactedURL returns a URL without its query, where CivitAI's token and
+// presigned signatures travel, for logs and error messages
+func redactedURL(u *url.URL) string {
+	return u.Host + u.Path
+}
+
+// redactError strips the query from the URL in a request error, so the
+// error can be logged, saved and reported without leaking a token.
+// Wrapping errors have already copied the URL into their messages, so
+// the message is rewritten too, keeping the chain for errors.Is.
+func redactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	full := urlErr.URL
+	stripped, _, found := strings.Cut(full, "?")
+	if !found {
+		return err
+	}
+	urlErr.URL = stripped
+	return &redactedError{msg: strings.ReplaceAll(err.Error(), full, stripped), err: err}
+}
+
+// redactedError is an error whose message has had a URL query removed
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
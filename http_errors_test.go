@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	downloadURL := server.URL + "/api/download/models/1?token=secret"
+	server.Close()
+
+	_, err := http.Get(downloadURL)
+	if err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	err = redactError(fmt.Errorf("attempt failed: %w", err))
+
+	if msg := err.Error(); strings.Contains(msg, "secret") || !strings.Contains(msg, "/api/download/models/1") {
+		t.Errorf("redacted error = %q, want the URL without its query", msg)
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !isConnectionError(err) {
+		t.Errorf("redacted error lost its cause: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// version is reported in the User-Agent; set at build time with
+// -ldflags "-X main.version=..."
+var version = "dev"
+
+// defaultUserAgent identifies this tool to the APIs
+func defaultUserAgent() string {
+	return fmt.Sprintf("comfyui-model-manager/%s (+https://github.com/niuguy/comfyui-model-manager)", version)
+}
+
+// UserAgentString returns the configured User-Agent or the default
+func (c *Config) UserAgentString() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return defaultUserAgent()
+}
+
+// headerTransport adds default headers to requests that don't set them
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+// newHeaderTransport returns a transport sending the configured
+// User-Agent, plus any extra headers given as key/value pairs
+func newHeaderTransport(config *Config, keyValues ...string) *headerTransport {
+	headers := http.Header{}
+	headers.Set("User-Agent", config.UserAgentString())
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		headers.Set(keyValues[i], keyValues[i+1])
+	}
+	return &headerTransport{base: config.baseTransport(), headers: headers}
+}
+
+// baseTransport returns the configured Transport or http.DefaultTransport
+func (c *Config) baseTransport() http.RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return http.DefaultTransport
+}
+
+// withBase returns t with rt beneath its retry, compression and header
+// layers, which are kept. A transport that isn't one of those layers is
+// replaced by rt.
+func withBase(t http.RoundTripper, rt http.RoundTripper) http.RoundTripper {
+	switch t := t.(type) {
+	case *retryTransport:
+		t.base = withBase(t.base, rt)
+		return t
+	case *compressTransport:
+		t.base = withBase(t.base, rt)
+		return t
+	case *headerTransport:
+		t.base = withBase(t.base, rt)
+		return t
+	default:
+		return rt
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		if req.Header.Get(key) == "" {
+			req.Header[key] = values
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// authorize sets a source's credentials on a request: the token as a
+// Bearer Authorization header, then the source's custom headers with
+// "{token}" expanded. An empty custom value removes that header.
+func authorize(req *http.Request, token string, custom map[string]string) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, value := range custom {
+		if value == "" {
+			req.Header.Del(key)
+			continue
+		}
+		req.Header.Set(key, strings.ReplaceAll(value, "{token}", token))
+	}
+}
+
+// stripOnRedirect returns a CheckRedirect that drops custom headers when
+// a redirect leaves the original host. Go only does this for Authorization
+// and cookies, and downloads often redirect to a CDN.
+func stripOnRedirect(custom map[string]string) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		if req.URL.Host != via[0].URL.Host {
+			for key := range custom {
+				req.Header.Del(key)
+			}
+		}
+		return nil
+	}
+}
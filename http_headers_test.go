@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc serves requests with a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSetTransportKeepsLayers(t *testing.T) {
+	config := newTestConfig(t)
+	config.RetryAttempts = 2
+	config.MaxRetryBackoff = time.Millisecond
+	manager := NewDownloadManager(config)
+
+	var calls int
+	var userAgent string
+	manager.hfClient.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		userAgent = req.Header.Get("User-Agent")
+		status := http.StatusOK
+		if calls == 1 {
+			status = http.StatusServiceUnavailable
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader("[]")),
+			Request:    req,
+		}, nil
+	}))
+
+	resp, err := manager.hfClient.httpClient.Get("https://huggingface.co/api/models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if calls != 2 {
+		t.Errorf("transport got %d requests, want 2: the 503 should be retried", calls)
+	}
+	if userAgent != config.UserAgentString() {
+		t.Errorf("User-Agent = %q, want %q", userAgent, config.UserAgentString())
+	}
+}
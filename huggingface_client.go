@@ -1,19 +1,38 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // HuggingFaceClient handles searching and downloading from HuggingFace
 type HuggingFaceClient struct {
+	baseURL    string // see SetBaseURL
 	token      string
 	httpClient *http.Client
+	cache      *responseCache
+	policy     FormatPolicy
+	headers    map[string]string // custom auth headers, see authorize
+	trusted    trustList         // authors searches may return; nil allows all
+	// filePattern, from --hf-file-pattern, limits the files considered
+	filePattern string
+
+	// Downloads run far longer than API calls, so they use a client
+	// without an overall timeout and rely on stall detection instead
+	downloadClient *http.Client
+	stallTimeout   time.Duration
+	chunks         int   // parallel range requests per file
+	syncEvery      int64 // bytes between flushes of a partial file
 }
 
 // HFSearchResponse represents the HuggingFace search API response
@@ -32,8 +51,11 @@ type HFModel struct {
 	LibraryName  string   `json:"library_name"`
 }
 
-// HFRepoFile represents a file in a HuggingFace repository
+// HFRepoFile represents a file in a HuggingFace repository. The tree API
+// names it Path; model siblings name it RFilename.
 type HFRepoFile struct {
+	Type      string `json:"type"` // "file" or "directory" in tree listings
+	Path      string `json:"path"`
 	RFilename string `json:"rfilename"`
 	Size      int64  `json:"size"`
 	BlobID    string `json:"blobId"`
@@ -44,22 +66,53 @@ type HFRepoFile struct {
 	} `json:"lfs,omitempty"`
 }
 
+// Filename returns the file's path within the repository
+func (f HFRepoFile) Filename() string {
+	if f.Path != "" {
+		return f.Path
+	}
+	return f.RFilename
+}
+
+// defaultHFBaseURL is where HuggingFace's site and API are served
+const defaultHFBaseURL = "https://huggingface.co"
+
 // NewHuggingFaceClient creates a new HuggingFace client
 func NewHuggingFaceClient(token string) *HuggingFaceClient {
 	return &HuggingFaceClient{
-		token: token,
+		baseURL: defaultHFBaseURL,
+		token:   token,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		downloadClient: &http.Client{},
 	}
 }
 
+// SetTransport sends API and download requests through rt, beneath the
+// client's retries and default headers
+func (h *HuggingFaceClient) SetTransport(rt http.RoundTripper) {
+	h.httpClient.Transport = withBase(h.httpClient.Transport, rt)
+	h.downloadClient.Transport = withBase(h.downloadClient.Transport, rt)
+}
+
+// SetBaseURL points API requests and download URLs at another server,
+// e.g. a fixture server in place of https://huggingface.co
+func (h *HuggingFaceClient) SetBaseURL(base string) {
+	h.baseURL = strings.TrimSuffix(base, "/")
+}
+
+// resolveURL returns the download URL of a file in a repository revision
+func (h *HuggingFaceClient) resolveURL(repoID, revision, name string) string {
+	return fmt.Sprintf("%s/%s/resolve/%s/%s", h.baseURL, repoID, revision, name)
+}
+
 // SearchModels searches for models on HuggingFace
 func (h *HuggingFaceClient) SearchModels(query string, modelType ModelType) ([]SearchResult, error) {
 	// Map ComfyUI model types to HF tags/filters
 	hfTags := h.getHFTags(modelType)
 
-	searchURL := "https://huggingface.co/api/models"
+	searchURL := h.baseURL + "/api/models"
 	params := url.Values{}
 	params.Add("search", query)
 	params.Add("limit", "10")
@@ -76,19 +129,16 @@ func (h *HuggingFaceClient) SearchModels(query string, modelType ModelType) ([]S
 		return nil, err
 	}
 
-	if h.token != "" {
-		req.Header.Set("Authorization", "Bearer "+h.token)
-	}
+	authorize(req, h.token, h.headers)
 
-	resp, err := h.httpClient.Do(req)
+	resp, err := h.cache.Do(h.httpClient, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HF API error: %s - %s", resp.Status, string(body))
+		return nil, newHTTPStatusError("HF API error", resp, true)
 	}
 
 	var hfModels HFSearchResponse
@@ -98,7 +148,14 @@ func (h *HuggingFaceClient) SearchModels(query string, modelType ModelType) ([]S
 
 	// Convert to SearchResults
 	results := []SearchResult{}
+	untrusted := 0
 	for _, model := range hfModels {
+		model.Author = hfAuthor(model)
+		if !h.trusted.Allows(model.Author) {
+			log.Printf("Skipping %s: %s is not in trusted_hf_authors\n", model.ID, model.Author)
+			untrusted++
+			continue
+		}
 		result, err := h.getModelFiles(model, modelType)
 		if err != nil {
 			continue // Skip models we can't get files for
@@ -106,30 +163,36 @@ func (h *HuggingFaceClient) SearchModels(query string, modelType ModelType) ([]S
 		results = append(results, result...)
 	}
 
+	if len(results) == 0 && untrusted > 0 && untrusted == len(hfModels) {
+		return nil, fmt.Errorf("%w (%d HuggingFace repositories); --allow-untrusted uses them", ErrUntrusted, untrusted)
+	}
+	if len(results) == 0 && len(hfModels) > 0 {
+		return nil, fmt.Errorf("%w: %d HuggingFace repositories had no matching model files", ErrAllFiltered, len(hfModels))
+	}
+
 	return results, nil
 }
 
 // getModelFiles gets the downloadable files for a model
 func (h *HuggingFaceClient) getModelFiles(model HFModel, modelType ModelType) ([]SearchResult, error) {
-	filesURL := fmt.Sprintf("https://huggingface.co/api/models/%s/tree/main", model.ID)
+	// List recursively so folder conventions like vae/ can be used
+	filesURL := fmt.Sprintf("%s/api/models/%s/tree/main?recursive=true", h.baseURL, model.ID)
 
 	req, err := http.NewRequest("GET", filesURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if h.token != "" {
-		req.Header.Set("Authorization", "Bearer "+h.token)
-	}
+	authorize(req, h.token, h.headers)
 
-	resp, err := h.httpClient.Do(req)
+	resp, err := h.cache.Do(h.httpClient, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get model files: %s", resp.Status)
+		return nil, newHTTPStatusError("failed to get model files", resp, false)
 	}
 
 	var files []HFRepoFile
@@ -139,13 +202,14 @@ func (h *HuggingFaceClient) getModelFiles(model HFModel, modelType ModelType) ([
 
 	results := []SearchResult{}
 	for _, file := range files {
-		if h.isModelFile(file.RFilename, modelType) {
+		if h.isModelFile(file.Filename(), model, modelType) {
 			result := SearchResult{
-				Name:        file.RFilename,
+				Name:        file.Filename(),
 				Source:      "huggingface",
-				DownloadURL: fmt.Sprintf("https://huggingface.co/%s/resolve/main/%s", model.ID, file.RFilename),
+				DownloadURL: h.resolveURL(model.ID, "main", file.Filename()),
 				Size:        file.Size,
 				ModelType:   modelType,
+				Creator:     model.Author,
 			}
 
 			if file.LFS != nil {
@@ -157,9 +221,15 @@ func (h *HuggingFaceClient) getModelFiles(model HFModel, modelType ModelType) ([
 		}
 	}
 
+	h.policy.Rank(results)
 	return results, nil
 }
 
+// ListModelFiles lists the model files in a repository
+func (h *HuggingFaceClient) ListModelFiles(repoID string, modelType ModelType) ([]SearchResult, error) {
+	return h.getModelFiles(HFModel{ID: repoID}, modelType)
+}
+
 // getHFTags returns HuggingFace tags for a model type
 func (h *HuggingFaceClient) getHFTags(modelType ModelType) []string {
 	switch modelType {
@@ -175,17 +245,19 @@ func (h *HuggingFaceClient) getHFTags(modelType ModelType) []string {
 		return []string{"super-resolution", "image-enhancement"}
 	case ModelTypeClipVision:
 		return []string{"clip", "vision"}
+	case ModelTypeCLIP, ModelTypeTextEncoder:
+		return []string{"text-encoder"}
 	default:
 		return []string{}
 	}
 }
 
-// isModelFile checks if a filename is likely a model file
-func (h *HuggingFaceClient) isModelFile(filename string, modelType ModelType) bool {
+// isModelFile checks if a repository file is a model of the requested type
+func (h *HuggingFaceClient) isModelFile(filename string, model HFModel, modelType ModelType) bool {
 	lower := strings.ToLower(filename)
 
 	// Check common model file extensions
-	modelExts := []string{".safetensors", ".ckpt", ".pt", ".pth", ".bin"}
+	modelExts := []string{".safetensors", ".sft", ".gguf", ".ckpt", ".pt", ".pth", ".bin"}
 	hasModelExt := false
 	for _, ext := range modelExts {
 		if strings.HasSuffix(lower, ext) {
@@ -194,44 +266,157 @@ func (h *HuggingFaceClient) isModelFile(filename string, modelType ModelType) bo
 		}
 	}
 
-	if !hasModelExt {
+	if !hasModelExt || isTrainingArtifact(filename) || !h.matchesFilePattern(filename) {
 		return false
 	}
 
-	// Filter based on model type
-	switch modelType {
-	case ModelTypeVAE:
-		return strings.Contains(lower, "vae")
-	case ModelTypeLora:
-		return strings.Contains(lower, "lora") || !strings.Contains(lower, "vae")
-	case ModelTypeCheckpoint:
-		// Avoid VAE and LoRA files
-		return !strings.Contains(lower, "vae") && !strings.Contains(lower, "lora")
-	default:
-		return true
+	// Files with no type signal are trusted to match the search
+	kind := classifyHFFile(filename, model)
+	if kind != "" && modelType != "" && !sameModelType(kind, modelType) {
+		return false
+	}
+
+	if err := h.policy.Check(filename, false); err != nil {
+		log.Printf("Skipping %s/%s: %v\n", model.ID, filename, err)
+		return false
+	}
+
+	return true
+}
+
+// hfFolderTypes maps conventional repository folders to model types
+var hfFolderTypes = map[string]ModelType{
+	"vae":            ModelTypeVAE,
+	"text_encoder":   ModelTypeTextEncoder,
+	"text_encoder_2": ModelTypeTextEncoder,
+	"text_encoder_3": ModelTypeTextEncoder,
+	"text_encoders":  ModelTypeTextEncoder,
+	"clip":           ModelTypeTextEncoder,
+	"controlnet":     ModelTypeControlNet,
+	"lora":           ModelTypeLora,
+	"loras":          ModelTypeLora,
+	"embeddings":     ModelTypeEmbedding,
+	"clip_vision":    ModelTypeClipVision,
+	"image_encoder":  ModelTypeClipVision,
+}
+
+// classifyHFFile infers a file's model type from its folder, its name
+// tokens and the repository tags, returning "" when there is no signal
+func classifyHFFile(filename string, model HFModel) ModelType {
+	// Folder conventions are the strongest signal (e.g. vae/ in diffusers repos)
+	parts := strings.Split(strings.ToLower(filename), "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if modelType, ok := hfFolderTypes[dir]; ok {
+			return modelType
+		}
+	}
+
+	// Whole-token matches on the file name, so "dreamshaper_lora_merged"
+	// is a merged checkpoint and "bakedvae" doesn't make a file a VAE
+	base := parts[len(parts)-1]
+	base = strings.TrimSuffix(base, path.Ext(base))
+	tokens := strings.FieldsFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	has := func(words ...string) bool {
+		for _, token := range tokens {
+			for _, word := range words {
+				if token == word {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("merged", "merge"):
+		return ModelTypeCheckpoint
+	case has("vae"):
+		return ModelTypeVAE
+	case has("lora", "locon", "lycoris", "loha", "lokr"):
+		return ModelTypeLora
+	}
+
+	// Fall back to what the repository says it is
+	for _, tag := range model.Tags {
+		switch strings.ToLower(tag) {
+		case "lora":
+			return ModelTypeLora
+		case "controlnet":
+			return ModelTypeControlNet
+		}
 	}
+
+	return ""
+}
+
+// isTextEncoderType reports whether a type is one of the text encoder folders
+func isTextEncoderType(modelType ModelType) bool {
+	return modelType == ModelTypeTextEncoder || modelType == ModelTypeCLIP
 }
 
 // DownloadFile downloads a file from HuggingFace
-func (h *HuggingFaceClient) DownloadFile(downloadURL, destPath string, onProgress func(downloaded, total int64)) error {
-	req, err := http.NewRequest("GET", downloadURL, nil)
+func (h *HuggingFaceClient) DownloadFile(ctx context.Context, downloadURL, destPath string, sum *streamSum, onProgress func(downloaded, total int64)) error {
+	guard := newStallGuard(ctx, h.stallTimeout)
+	defer guard.Stop()
+
+	req, err := http.NewRequestWithContext(guard.Context(), "GET", downloadURL, nil)
 	if err != nil {
 		return err
 	}
 
-	if h.token != "" {
-		req.Header.Set("Authorization", "Bearer "+h.token)
+	authorize(req, h.token, h.headers)
+
+	// Fail fast on dead links and learn the size before streaming
+	info, err := headCheck(h.downloadClient, req)
+	if err != nil {
+		return guard.Err(err)
+	}
+
+	if chunks := useChunks(h.chunks, info, destPath); chunks > 1 {
+		err = downloadChunked(h.downloadClient, req, destPath, info.Size, chunks, h.syncEvery, sum, guard.Wrap, checkDownloadStatus, onProgress)
+		return guard.Err(err)
 	}
 
-	resp, err := h.httpClient.Do(req)
+	err = streamDownload(h.downloadClient, req, destPath, h.syncEvery, sum, guard.Wrap, checkDownloadStatus, onProgress)
+	return guard.Err(err)
+}
+
+// lfsPointerPrefix starts every Git LFS pointer file
+const lfsPointerPrefix = "version https://git-lfs.github.com/spec/"
+
+// ErrLFSPointer is returned when HuggingFace served a Git LFS pointer
+// instead of the content the repo listing described
+var ErrLFSPointer = errors.New("received a Git LFS pointer instead of the file")
+
+// ErrSizeMismatch is returned when a download's size differs from the
+// size the repo listing gave
+var ErrSizeMismatch = errors.New("downloaded file size does not match the listing")
+
+// checkLFSContent verifies a downloaded file is real content: not an LFS
+// pointer and, when the listing gave a size, exactly that size
+func checkLFSContent(path string, expectedSize int64) error {
+	info, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: %s", resp.Status)
+	// Pointers are ~130 bytes; only small files are worth sniffing
+	if info.Size() < 1024 {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(string(data), lfsPointerPrefix) {
+			return fmt.Errorf("%w (%d bytes)", ErrLFSPointer, info.Size())
+		}
+	}
+
+	if expectedSize > 0 && info.Size() != expectedSize {
+		return fmt.Errorf("%w: got %d bytes, listing says %d", ErrSizeMismatch, info.Size(), expectedSize)
 	}
 
-	return downloadFile(resp.Body, destPath, resp.ContentLength, onProgress)
+	return nil
 }
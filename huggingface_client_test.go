@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsModelFile(t *testing.T) {
+	diffusers := HFModel{ID: "stabilityai/stable-diffusion-xl-base-1.0", Tags: []string{"diffusers", "text-to-image"}}
+	loraRepo := HFModel{ID: "someone/detail-tweaker", Tags: []string{"lora", "stable-diffusion"}}
+	plain := HFModel{ID: "someone/models"}
+
+	tests := []struct {
+		filename string
+		model    HFModel
+		want     ModelType
+		ok       bool
+	}{
+		// Diffusers folders decide the type
+		{"vae/diffusion_pytorch_model.safetensors", diffusers, ModelTypeVAE, true},
+		{"vae/diffusion_pytorch_model.safetensors", diffusers, ModelTypeCheckpoint, false},
+		{"text_encoder_2/model.safetensors", diffusers, ModelTypeCLIP, true},
+		{"text_encoder/model.fp16.safetensors", diffusers, ModelTypeTextEncoder, true},
+		{"text_encoder/model.safetensors", diffusers, ModelTypeVAE, false},
+		{"controlnet/diffusion_pytorch_model.safetensors", plain, ModelTypeControlNet, true},
+		{"image_encoder/model.safetensors", plain, ModelTypeClipVision, true},
+		{"sd_xl_base_1.0.safetensors", diffusers, ModelTypeCheckpoint, true},
+
+		// Name tokens
+		{"sdxl_vae.safetensors", plain, ModelTypeVAE, true},
+		{"sdxl_vae.safetensors", plain, ModelTypeCheckpoint, false},
+		{"juggernautXL_bakedvae.safetensors", plain, ModelTypeCheckpoint, true},
+		{"pytorch_lora_weights.safetensors", plain, ModelTypeLora, true},
+		{"add_detail_lora.safetensors", plain, ModelTypeCheckpoint, false},
+		{"dreamshaper_8_lora_merged.safetensors", plain, ModelTypeCheckpoint, true},
+		{"dreamshaper_8_lora_merged.safetensors", plain, ModelTypeLora, false},
+
+		// Repository tags when the file itself says nothing
+		{"add_detail.safetensors", loraRepo, ModelTypeLora, true},
+		{"add_detail.safetensors", loraRepo, ModelTypeCheckpoint, false},
+
+		// Not weights at all
+		{"README.md", plain, ModelTypeCheckpoint, false},
+		{"model_index.json", diffusers, ModelTypeCheckpoint, false},
+		{"optimizer.pt", plain, ModelTypeCheckpoint, false},
+		{"checkpoint-500/model.safetensors", plain, ModelTypeCheckpoint, false},
+	}
+
+	client := NewHuggingFaceClient("")
+	client.policy = DefaultConfig().FormatPolicy()
+	for _, tt := range tests {
+		if got := client.isModelFile(tt.filename, tt.model, tt.want); got != tt.ok {
+			t.Errorf("isModelFile(%q, %s, %s) = %v, want %v", tt.filename, tt.model.ID, tt.want, got, tt.ok)
+		}
+	}
+}
+
+func TestCheckLFSContent(t *testing.T) {
+	pointer := lfsPointerPrefix + "v1\noid sha256:abc\nsize 6\n"
+	tests := []struct {
+		name     string
+		content  string
+		expected int64
+		want     error
+	}{
+		{"pointer", pointer, 6, ErrLFSPointer},
+		{"short", "wei", 6, ErrSizeMismatch},
+		{"long", "weights!", 6, ErrSizeMismatch},
+		{"exact", "weight", 6, nil},
+		{"no listed size", "weights!", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "model.safetensors.tmp")
+			writeFile(t, path, []byte(tt.content))
+			err := checkLFSContent(path, tt.expected)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("checkLFSContent = %v, want %v", err, tt.want)
+			}
+			if tt.want == ErrSizeMismatch && errors.Is(err, ErrLFSPointer) {
+				t.Errorf("size mismatch reported as an LFS pointer: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// layouts are the model folder conventions of the UIs that may share a
+// model store, by name
+var layouts = map[string]map[ModelType]string{
+	"comfyui": {
+		ModelTypeCheckpoint:  "models/checkpoints",
+		ModelTypeLora:        "models/loras",
+		ModelTypeVAE:         "models/vae",
+		ModelTypeEmbedding:   "models/embeddings",
+		ModelTypeControlNet:  "models/controlnet",
+		ModelTypeUpscale:     "models/upscale_models",
+		ModelTypeClipVision:  "models/clip_vision",
+		ModelTypeCLIP:        "models/clip",
+		ModelTypeTextEncoder: "models/text_encoders",
+		ModelTypeDiffusers:   "models/diffusers",
+	},
+	// Automatic1111 and Forge
+	"a1111": {
+		ModelTypeCheckpoint:  "models/Stable-diffusion",
+		ModelTypeLora:        "models/Lora",
+		ModelTypeVAE:         "models/VAE",
+		ModelTypeEmbedding:   "embeddings",
+		ModelTypeControlNet:  "models/ControlNet",
+		ModelTypeUpscale:     "models/ESRGAN",
+		ModelTypeClipVision:  "models/clip_vision",
+		ModelTypeCLIP:        "models/text_encoder",
+		ModelTypeTextEncoder: "models/text_encoder",
+		ModelTypeDiffusers:   "models/diffusers",
+	},
+}
+
+// layoutNames lists the known layouts for messages
+func layoutNames() string {
+	names := make([]string, 0, len(layouts))
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// layoutDirs returns a layout's folders in the form of model_dirs
+func layoutDirs(name string) (map[string]string, error) {
+	layout, ok := layouts[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown layout %q (known layouts: %s)", name, layoutNames())
+	}
+	dirs := make(map[string]string, len(layout))
+	for modelType, dir := range layout {
+		dirs[string(modelType)] = dir
+	}
+	return dirs, nil
+}
+
+// ApplyLayout switches every model type to a layout's folders, replacing
+// model_dirs; model_path_overrides and --map-dir still take precedence
+func (c *Config) ApplyLayout(name string) error {
+	dirs, err := layoutDirs(name)
+	if err != nil {
+		return err
+	}
+	c.Layout = name
+	c.ModelDirs = dirs
+	return nil
+}
+
+// applyConfigLayout applies the layout a config file names. Its
+// model_dirs entries still win where they differ from ComfyUI's folders;
+// ones that match are taken as the defaults a generated config carries.
+// An unknown layout is left for Validate to report.
+func (c *Config) applyConfigLayout() {
+	if c.Layout == "" {
+		return
+	}
+	dirs, err := layoutDirs(c.Layout)
+	if err != nil {
+		return
+	}
+
+	defaults := layouts["comfyui"]
+	for name, dir := range dirs {
+		if current, ok := c.ModelDirs[name]; !ok || current == defaults[ModelType(name)] {
+			c.ModelDirs[name] = dir
+		}
+	}
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
-	"sync"
+	"syscall"
+	"time"
 )
 
 // ModelManager is the main application struct
@@ -17,6 +19,13 @@ type ModelManager struct {
 	parser     *WorkflowParser
 	scanner    *ModelScanner
 	downloader *DownloadManager
+	modelList  *ModelList
+	lastRun    *RunReport // for --report
+	force      bool
+	assumeYes  bool // --yes
+
+	resolvers       map[string]Resolver
+	customResolvers []string // registration order of non-built-in resolvers
 }
 
 // NewModelManager creates a new model manager instance
@@ -26,16 +35,95 @@ func NewModelManager(configPath string) (*ModelManager, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	return &ModelManager{
+	manager := &ModelManager{
 		config:     config,
 		parser:     NewWorkflowParser(config),
 		scanner:    NewModelScanner(config),
 		downloader: NewDownloadManager(config),
-	}, nil
+	}
+
+	if config.ModelList != "" {
+		manager.modelList, err = LoadModelList(config.ModelList)
+		if err != nil {
+			log.Printf("Ignoring model list: %v\n", err)
+		}
+	}
+	manager.registerBuiltinResolvers()
+
+	return manager, nil
+}
+
+// SetWorkers overrides the number of parallel downloads
+func (m *ModelManager) SetWorkers(n int) {
+	m.config.MaxWorkers = n
+	m.downloader.workers = n
+}
+
+// SetComfyUIPath points the manager at another ComfyUI install, warning
+// when it doesn't look like one
+func (m *ModelManager) SetComfyUIPath(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	m.config.ComfyUIPath = path
+
+	switch {
+	case !dirExists(path):
+		log.Printf("Warning: ComfyUI path %s does not exist\n", path)
+	case !dirExists(filepath.Join(path, "models")):
+		log.Printf("Warning: %s has no models directory; is it a ComfyUI install?\n", path)
+	}
+}
+
+// SetPreferFormat overrides the extension preferred among a model's formats
+func (m *ModelManager) SetPreferFormat(ext string) {
+	m.config.PreferFormat = ext
+	m.downloader.hfClient.policy.Prefer = ext
+	m.downloader.civitClient.policy.Prefer = ext
+}
+
+// SetDownloadOrder sets the order downloads are queued in: "size" for
+// smallest first, "type" for support files before checkpoints, or "" for
+// the order models were found in
+func (m *ModelManager) SetDownloadOrder(order string) error {
+	if err := validDownloadOrder(order); err != nil {
+		return err
+	}
+	m.downloader.order = order
+	return nil
+}
+
+// SetHFFilePattern limits HuggingFace searches and --get to repository
+// files matching a glob
+func (m *ModelManager) SetHFFilePattern(pattern string) error {
+	return m.downloader.hfClient.SetFilePattern(pattern)
+}
+
+// SetForce makes runs re-download models that are already present. With
+// verify, files whose hash matches the source are kept.
+func (m *ModelManager) SetForce(force, verify bool) {
+	m.force = force
+	m.downloader.overwrite = force && !verify
+}
+
+// applyForce treats present models as missing when forcing re-downloads.
+// Scanning first keeps the paths of files found under a different case,
+// or with the extension a name without one resolved to, so they are
+// overwritten in place.
+func (m *ModelManager) applyForce(present, missing []Model) ([]Model, []Model) {
+	if !m.force || len(present) == 0 {
+		return present, missing
+	}
+	fmt.Printf("Forcing re-download of %d present models\n", len(present))
+	for _, model := range present {
+		model.IsPresent = false
+		missing = append(missing, model)
+	}
+	return nil, missing
 }
 
 // ProcessWorkflow processes a ComfyUI workflow and downloads missing models
-func (m *ModelManager) ProcessWorkflow(workflowPath string) error {
+func (m *ModelManager) ProcessWorkflow(ctx context.Context, workflowPath string) error {
 	fmt.Printf("Processing workflow: %s\n", workflowPath)
 
 	// Step 1: Parse workflow
@@ -55,7 +143,14 @@ func (m *ModelManager) ProcessWorkflow(workflowPath string) error {
 
 	fmt.Printf("Present models: %d\n", len(present))
 	fmt.Printf("Missing models: %d\n", len(missing))
+	present, missing = m.applyForce(present, missing)
 
+	m.lastRun = &RunReport{Workflow: workflowPath, Present: present, Missing: missing}
+	return m.downloadMissing(ctx, missing)
+}
+
+// downloadMissing searches for missing models and downloads those found
+func (m *ModelManager) downloadMissing(ctx context.Context, missing []Model) error {
 	if len(missing) == 0 {
 		fmt.Println("\nAll models are present! No downloads needed.")
 		return nil
@@ -69,7 +164,11 @@ func (m *ModelManager) ProcessWorkflow(workflowPath string) error {
 
 	// Step 3: Search for missing models
 	fmt.Println("\n3. Searching for models...")
-	searchResults := m.searchModels(missing)
+	searchResults, reports := m.searchModels(missing)
+	if m.lastRun != nil {
+		m.lastRun.Results = searchResults
+		m.lastRun.Searches = reports
+	}
 
 	// Print search results
 	fmt.Printf("\nFound %d models online:\n", len(searchResults))
@@ -90,14 +189,20 @@ func (m *ModelManager) ProcessWorkflow(workflowPath string) error {
 		fmt.Println("\nCould not find these models:")
 		for _, model := range notFound {
 			fmt.Printf("  - %s (%s)\n", model.Name, model.Type)
+			for _, attempt := range reports[model.Name].Attempts {
+				fmt.Printf("      %s: %s\n", attempt.Source, attempt.Outcome)
+			}
 		}
 	}
 
 	// Step 4: Download missing models
 	if len(searchResults) > 0 {
+		if err := m.confirmDownload(missing, searchResults); err != nil {
+			return err
+		}
+
 		fmt.Println("\n4. Downloading models...")
-		err = m.downloader.DownloadModels(missing, searchResults)
-		if err != nil {
+		if err := m.downloadAndSummarize(ctx, missing, searchResults); err != nil {
 			return fmt.Errorf("download failed: %w", err)
 		}
 		fmt.Println("\nAll downloads completed!")
@@ -106,98 +211,33 @@ func (m *ModelManager) ProcessWorkflow(workflowPath string) error {
 	return nil
 }
 
-// searchModels searches for models on HuggingFace and CivitAI
-func (m *ModelManager) searchModels(models []Model) map[string]SearchResult {
-	results := make(map[string]SearchResult)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// Search concurrently
-	for _, model := range models {
-		wg.Add(1)
-		go func(model Model) {
-			defer wg.Done()
-
-			result := m.searchModel(model)
-			if result != nil {
-				mu.Lock()
-				results[model.Name] = *result
-				mu.Unlock()
-			}
-		}(model)
+// ScanAllModels scans all model directories, printing the models that
+// pass filter as a list per type or, with asJSON, as a JSON array
+func (m *ModelManager) ScanAllModels(filter ModelFilter, asJSON bool) error {
+	if !asJSON {
+		fmt.Println("Scanning all model directories...")
 	}
 
-	wg.Wait()
-	return results
-}
-
-// searchModel searches for a single model
-func (m *ModelManager) searchModel(model Model) *SearchResult {
-	// Clean up model name for searching
-	searchName := cleanModelName(model.Name)
-
-	// Try HuggingFace first
-	if m.config.HuggingFaceToken != "" {
-		hfResults, err := m.downloader.hfClient.SearchModels(searchName, model.Type)
-		if err == nil && len(hfResults) > 0 {
-			// Return the first result
-			return &hfResults[0]
+	all := []Model{}
+	for _, modelType := range knownModelTypes {
+		if !m.config.TypeFilter.Allows(modelType) || (filter.Type != "" && modelType != filter.Type) {
+			continue
 		}
-	}
 
-	// Try CivitAI
-	civitResults, err := m.downloader.civitClient.SearchModels(searchName, model.Type)
-	if err == nil && len(civitResults) > 0 {
-		// Return the first result
-		return &civitResults[0]
-	}
+		scanned, err := m.scanner.ScanDirectory(modelType)
+		if err != nil {
+			log.Printf("Error scanning %s: %v\n", modelType, err)
+			continue
+		}
 
-	// Try searching by hash if available
-	if model.Hash != "" {
-		if m.config.CivitAIToken != "" {
-			result, err := m.downloader.civitClient.GetModelByHash(model.Hash)
-			if err == nil && result != nil {
-				result.ModelType = model.Type
-				return result
+		var models []Model
+		for _, model := range scanned {
+			if filter.Matches(model) {
+				models = append(models, model)
 			}
 		}
-	}
-
-	return nil
-}
-
-// cleanModelName cleans up a model name for searching
-func cleanModelName(name string) string {
-	// Remove file extension
-	name = strings.TrimSuffix(name, filepath.Ext(name))
-
-	// Remove common suffixes
-	suffixes := []string{"_fp16", "_fp32", "-fp16", "-fp32", "_pruned", "-pruned"}
-	for _, suffix := range suffixes {
-		name = strings.TrimSuffix(name, suffix)
-	}
-
-	return name
-}
-
-// ScanAllModels scans all model directories
-func (m *ModelManager) ScanAllModels() error {
-	fmt.Println("Scanning all model directories...")
-
-	modelTypes := []ModelType{
-		ModelTypeCheckpoint,
-		ModelTypeLora,
-		ModelTypeVAE,
-		ModelTypeEmbedding,
-		ModelTypeControlNet,
-		ModelTypeUpscale,
-		ModelTypeClipVision,
-	}
-
-	for _, modelType := range modelTypes {
-		models, err := m.scanner.ScanDirectory(modelType)
-		if err != nil {
-			log.Printf("Error scanning %s: %v\n", modelType, err)
+		if asJSON {
+			all = append(all, models...)
 			continue
 		}
 
@@ -208,9 +248,30 @@ func (m *ModelManager) ScanAllModels() error {
 		}
 	}
 
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(all)
+	}
 	return nil
 }
 
+// PrintInterruptSummary reports which downloads finished before an interrupt
+func (m *ModelManager) PrintInterruptSummary() {
+	fmt.Println("\n\nInterrupted. Partial downloads were kept and will resume on the next run.")
+	if fileExists(m.config.BatchStateFile()) {
+		fmt.Println("Run with --resume-batch to continue this batch without searching again.")
+	}
+
+	for name, progress := range m.downloader.GetProgress() {
+		if progress.Completed {
+			fmt.Printf("  completed: %s\n", name)
+		} else {
+			fmt.Printf("  partial:   %s (%.2f MB)\n", name, float64(progress.Downloaded)/(1024*1024))
+		}
+	}
+}
+
 // SaveConfig saves the current configuration
 func (m *ModelManager) SaveConfig(path string) error {
 	data, err := json.MarshalIndent(m.config, "", "  ")
@@ -218,19 +279,68 @@ func (m *ModelManager) SaveConfig(path string) error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	return writeFileAtomic(path, data, 0644)
 }
 
 func main() {
 	var (
-		configPath   = flag.String("config", "config.json", "Configuration file path")
-		workflowPath = flag.String("workflow", "", "ComfyUI workflow file to process")
+		configPath   = flag.String("config", "", "Configuration file path (default $COMFY_MODEL_MANAGER_CONFIG, then $XDG_CONFIG_HOME or ~/.config/comfy-model-manager/config.json, then ./config.json, whichever exists first)")
+		workflowPath = flag.String("workflow", "", "ComfyUI workflow file, or PNG saved by ComfyUI, to process; - reads stdin and an http(s) URL is fetched")
+		workflowDir  = flag.String("workflow-dir", "", "Directory of ComfyUI workflows to process together")
+		since        = flag.String("since", "", "With --workflow-dir, only process workflows modified since a duration ago (36h, 7d) or a time")
 		scanOnly     = flag.Bool("scan", false, "Only scan for models, don't download")
+		resolve      = flag.Bool("resolve", false, "With --scan, also search for the missing models and group them by where they can be found")
 		listModels   = flag.Bool("list", false, "List all installed models")
+		showStatus   = flag.Bool("status", false, "Report model count and disk usage per type, and free disk space")
+		asJSON       = flag.Bool("json", false, "With --status or --list, print JSON")
+		filterName   = flag.String("filter-name", "", "With --list, only list models whose name contains this, ignoring case")
+		filterType   = flag.String("filter-type", "", "With --list, only list models of this type (e.g. loras)")
+		minSize      = flag.String("min-size", "", "With --list, only list models at least this large (e.g. 500MB)")
+		maxSize      = flag.String("max-size", "", "With --list, only list models at most this large (e.g. 2GB)")
+		cleanPartial = flag.Bool("clean-partial", false, "Delete the .tmp files of downloads that never finished")
+		checkSetup   = flag.Bool("check", false, "Validate the config, check the model directories are writable and test the API tokens")
 		genConfig    = flag.Bool("gen-config", false, "Generate default configuration file")
+		getSpec      = flag.String("get", "", "Download a single model by HF repo/file, HF URL, CivitAI model page or download URL, or CivitAI AIR (urn:air:...)")
+		allFiles     = flag.Bool("all-files", false, "With --get, download every file of the HF repo or folder, keeping its layout (e.g. diffusers models)")
+		typeName     = flag.String("type", "", "Model type for --get and --search (e.g. checkpoints, loras)")
+		searchQuery  = flag.String("search", "", "Search HuggingFace and CivitAI for a model without downloading")
+		sourceName   = flag.String("source", "", "Restrict --search to one source (huggingface or civitai)")
+		update       = flag.Bool("update", false, "Check downloaded CivitAI models for newer versions")
+		repair       = flag.Bool("repair", false, "Report installed models whose metadata or CivitAI hash says they are in the wrong type's directory")
+		apply        = flag.Bool("apply", false, "With --update, download the available updates; with --repair, move the misfiled models")
+		keepBackup   = flag.Bool("keep-old", false, "With --update --apply, keep the previous file as .bak")
+		workers      = flag.Int("workers", 0, "Number of parallel downloads (overrides config max_workers)")
+		sourceNames  = flag.Bool("use-source-filename", false, "Save downloads under the source's file name and link the workflow's name to it")
+		order        = flag.String("order", "", "Download order: size (smallest first) or type (VAE and text encoders before checkpoints); default is the order models were found in")
+		extraPaths   = flag.String("extra-model-paths", "", "ComfyUI extra_model_paths.yaml to search for existing models")
+		importPath   = flag.String("import-manifest", "", "Download the models listed in a manifest written by --export-manifest")
+		exportPath   = flag.String("export-manifest", "", "With --workflow, write a manifest of every referenced model to this file")
+		onlyTypes    = flag.String("types", "", "Comma-separated model types to process (globs allowed, e.g. loras,clip*)")
+		skipTypes    = flag.String("skip-types", "", "Comma-separated model types to ignore")
+		reportPath   = flag.String("report", "", "With --workflow or --workflow-dir, write a summary of the run to this file")
+		reportFormat = flag.String("report-format", "", "Report format: md or html (default inferred from the --report extension)")
+		force        = flag.Bool("force", false, "Re-download models even if they are already present")
+		resumeBatch  = flag.Bool("resume-batch", false, "Continue the last interrupted download batch without searching again")
+		assumeYes    = flag.Bool("yes", false, "Download without asking, even above confirm_above_gb")
+		verify       = flag.Bool("verify", false, "With --force, keep present files whose hash matches the source")
+		destDir      = flag.String("dest", "", "Download into this directory, keeping the per-type layout, instead of ComfyUIPath")
+		comfyUIPath  = flag.String("comfyui-path", "", "ComfyUI install to use (overrides $COMFYUI_PATH and config comfyui_path)")
+		heuristic    = flag.Bool("heuristic-parse", false, "Guess model files in unrecognized workflow nodes from their input names")
+		allowUntrust = flag.Bool("allow-untrusted", false, "Use search results by authors outside trusted_hf_authors and trusted_civitai_creators")
+		fuzzy        = flag.Bool("fuzzy", false, "Treat a file as present when its name starts with or contains the workflow's model name")
+		anyDir       = flag.Bool("exclude-present-in-any-dir", false, "Treat a model as present when its file is in any model directory, reporting where it was found")
+		hfPattern    = flag.String("hf-file-pattern", "", "Only consider HuggingFace repository files matching this glob, e.g. '*fp16*.safetensors' (a pattern with / matches the whole path)")
+		preferFormat = flag.String("prefer-format", "", "Format to pick when a model offers several, e.g. safetensors or ckpt (overrides config prefer_format)")
+		layout       = flag.String("layout", "", "Model folder layout: comfyui or a1111 (Automatic1111/Forge); overrides config layout and model_dirs")
+		testServer   = flag.String("test-server", "", "Serve the model files in this directory as a local HuggingFace and CivitAI and use it instead of the real sites, for offline end-to-end runs")
+		mapDirs      = make(dirMappings)
 	)
+	flag.Var(mapDirs, "map-dir", "Use this directory for a model type, e.g. checkpoints=models/Stable-diffusion (repeatable; overrides config model_dirs)")
 
 	flag.Parse()
+	if *configPath == "" {
+		*configPath = findConfigPath()
+	}
 
 	// Generate config if requested
 	if *genConfig {
@@ -242,23 +352,261 @@ func main() {
 		return
 	}
 
+	// Cancel in-flight downloads cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Create model manager
 	manager, err := NewModelManager(*configPath)
 	if err != nil {
+		if *checkSetup {
+			fmt.Printf("FAIL  config: %v\n", err)
+			os.Exit(1)
+		}
 		log.Fatalf("Failed to initialize: %v", err)
 	}
 
+	// Pick the ComfyUI install: flag, then environment, then config
+	if path := *comfyUIPath; path != "" {
+		manager.SetComfyUIPath(path)
+	} else if path := os.Getenv("COMFYUI_PATH"); path != "" {
+		manager.SetComfyUIPath(path)
+	}
+
+	// Override download parallelism for this run
+	if *workers != 0 {
+		if *workers < 1 {
+			log.Fatalf("--workers must be at least 1, got %d", *workers)
+		}
+		manager.SetWorkers(*workers)
+	}
+
+	if err := manager.SetDownloadOrder(*order); err != nil {
+		log.Fatalf("Invalid --order: %v", err)
+	}
+
+	if *layout != "" {
+		if err := manager.config.ApplyLayout(*layout); err != nil {
+			log.Fatalf("Invalid --layout: %v", err)
+		}
+	}
+	if len(mapDirs) > 0 {
+		manager.config.MapDirs(mapDirs)
+	}
+
+	// Merge directories from ComfyUI's extra_model_paths.yaml
+	if *extraPaths != "" {
+		dirs, err := LoadExtraModelPaths(*extraPaths)
+		if err != nil {
+			log.Fatalf("Failed to load extra model paths: %v", err)
+		}
+		manager.config.AddExtraModelDirs(dirs)
+	}
+
+	// Restrict which model types are processed
+	if *onlyTypes != "" || *skipTypes != "" {
+		filter, err := ParseTypeFilter(*onlyTypes, *skipTypes)
+		if err != nil {
+			log.Fatalf("Invalid type filter: %v", err)
+		}
+		manager.config.TypeFilter = filter
+	}
+
+	manager.scanner.fuzzy = *fuzzy
+	manager.downloader.sourceNames = *sourceNames
+	manager.scanner.anyDir = *anyDir
+	manager.SetAllowUntrusted(*allowUntrust)
+	manager.parser.heuristic = *heuristic
+
+	if *hfPattern != "" {
+		if err := manager.SetHFFilePattern(*hfPattern); err != nil {
+			log.Fatalf("Invalid --hf-file-pattern: %v", err)
+		}
+	}
+
+	if *preferFormat != "" {
+		manager.SetPreferFormat(*preferFormat)
+	}
+
+	if *force {
+		manager.SetForce(true, *verify)
+	}
+	manager.assumeYes = *assumeYes
+
+	// Stage downloads outside the ComfyUI tree
+	if *destDir != "" {
+		abs, err := filepath.Abs(*destDir)
+		if err != nil {
+			log.Fatalf("Invalid --dest: %v", err)
+		}
+		manager.config.DestDir = abs
+	}
+
+	// Swap the real sites for local fixtures
+	if *testServer != "" {
+		stop, err := manager.UseFixtureServer(*testServer)
+		if err != nil {
+			log.Fatalf("Failed to start test server: %v", err)
+		}
+		defer stop()
+	}
+
+	// Report storage usage
+	if *showStatus {
+		if err := manager.PrintStatus(*asJSON); err != nil {
+			log.Fatalf("Failed to get status: %v", err)
+		}
+		return
+	}
+
+	if *checkSetup {
+		if err := manager.CheckSetup(ctx); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *cleanPartial {
+		if err := manager.CleanPartialDownloads(); err != nil {
+			log.Fatalf("Failed to clean incomplete downloads: %v", err)
+		}
+		return
+	}
+
 	// List models if requested
 	if *listModels {
-		if err := manager.ScanAllModels(); err != nil {
+		filter := ModelFilter{Name: *filterName}
+		if *filterType != "" {
+			if filter.Type, err = ParseModelType(*filterType); err != nil {
+				log.Fatalf("Invalid --filter-type: %v", err)
+			}
+		}
+		if *minSize != "" {
+			if filter.MinSize, err = ParseSize(*minSize); err != nil {
+				log.Fatalf("Invalid --min-size: %v", err)
+			}
+		}
+		if *maxSize != "" {
+			if filter.MaxSize, err = ParseSize(*maxSize); err != nil {
+				log.Fatalf("Invalid --max-size: %v", err)
+			}
+		}
+
+		if err := manager.ScanAllModels(filter, *asJSON); err != nil {
 			log.Fatalf("Failed to scan models: %v", err)
 		}
 		return
 	}
 
+	var modelType ModelType
+	if *typeName != "" {
+		modelType, err = ParseModelType(*typeName)
+		if err != nil {
+			log.Fatalf("Invalid --type: %v", err)
+		}
+	}
+
+	// Search without downloading if requested
+	if *searchQuery != "" {
+		if err := manager.Search(*searchQuery, modelType, *sourceName); err != nil {
+			log.Fatalf("Search failed: %v", err)
+		}
+		return
+	}
+
+	// Check for (and optionally apply) model updates
+	if *update {
+		if err := manager.UpdateModels(ctx, *apply, *keepBackup); err != nil {
+			exitIfInterrupted(ctx, manager)
+			log.Fatalf("Update failed: %v", err)
+		}
+		return
+	}
+
+	// Move misfiled models into their type's directory
+	if *repair {
+		if err := manager.RepairModels(*apply); err != nil {
+			log.Fatalf("Repair failed: %v", err)
+		}
+		return
+	}
+
+	// Download a single model if requested
+	if *getSpec != "" {
+		get := manager.GetModel
+		if *allFiles {
+			get = manager.GetRepo
+		}
+		if err := get(ctx, *getSpec, modelType); err != nil {
+			exitIfInterrupted(ctx, manager)
+			log.Fatalf("Failed to get model: %v", err)
+		}
+		return
+	}
+
+	// Continue an interrupted batch
+	if *resumeBatch {
+		if err := manager.ResumeBatch(ctx); err != nil {
+			exitIfInterrupted(ctx, manager)
+			log.Fatalf("Resume failed: %v", err)
+		}
+		return
+	}
+
+	// Provision the models listed in a manifest
+	if *importPath != "" {
+		if err := manager.ImportManifest(ctx, *importPath); err != nil {
+			exitIfInterrupted(ctx, manager)
+			log.Fatalf("Manifest import failed: %v", err)
+		}
+		return
+	}
+
+	// Write the run summary even when processing fails
+	writeReport := func() {
+		if *reportPath == "" {
+			return
+		}
+		if err := manager.WriteReport(*reportPath, *reportFormat); err != nil {
+			log.Printf("Failed to write report: %v\n", err)
+			return
+		}
+		fmt.Printf("Wrote report to %s\n", *reportPath)
+	}
+
+	// Process a directory of workflows
+	if *workflowDir != "" {
+		var modifiedSince time.Time
+		if *since != "" {
+			modifiedSince, err = ParseSince(*since, time.Now())
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+
+		err := manager.ProcessWorkflowDir(ctx, *workflowDir, modifiedSince)
+		writeReport()
+		if err != nil {
+			exitIfInterrupted(ctx, manager)
+			log.Fatalf("Workflow processing failed: %v", err)
+		}
+		return
+	}
+
 	// Process workflow
 	if *workflowPath != "" {
-		if *scanOnly {
+		if *exportPath != "" {
+			if err := manager.ExportManifest(*workflowPath, *exportPath); err != nil {
+				log.Fatalf("Failed to export manifest: %v", err)
+			}
+			return
+		}
+
+		if *scanOnly && *resolve {
+			if err := manager.ResolveWorkflow(*workflowPath); err != nil {
+				log.Fatalf("Failed to resolve models: %v", err)
+			}
+		} else if *scanOnly {
 			// Just scan and report
 			models, err := manager.parser.ParseWorkflow(*workflowPath)
 			if err != nil {
@@ -280,7 +628,10 @@ func main() {
 			}
 		} else {
 			// Full processing with downloads
-			if err := manager.ProcessWorkflow(*workflowPath); err != nil {
+			err := manager.ProcessWorkflow(ctx, *workflowPath)
+			writeReport()
+			if err != nil {
+				exitIfInterrupted(ctx, manager)
 				log.Fatalf("Workflow processing failed: %v", err)
 			}
 		}
@@ -291,6 +642,15 @@ func main() {
 	flag.Usage()
 }
 
+// exitIfInterrupted prints a summary and exits if ctx was cancelled by a signal
+func exitIfInterrupted(ctx context.Context, manager *ModelManager) {
+	if ctx.Err() == nil {
+		return
+	}
+	manager.PrintInterruptSummary()
+	os.Exit(130)
+}
+
 // saveDefaultConfig saves a default configuration file
 func saveDefaultConfig(path string, config *Config) error {
 	data, err := json.MarshalIndent(config, "", "  ")
@@ -298,5 +658,10 @@ func saveDefaultConfig(path string, config *Config) error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	// $COMFY_MODEL_MANAGER_CONFIG may point into a directory not made yet
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0644)
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ManifestEntry describes one model a workflow needs and where to get it
+type ManifestEntry struct {
+	Model  Model         `json:"model"`
+	Result *SearchResult `json:"result,omitempty"` // nil if no source was found
+}
+
+// Manifest is a portable description of every model a workflow references
+type Manifest struct {
+	Workflow  string          `json:"workflow"`
+	CreatedAt time.Time       `json:"created_at"`
+	Models    []ManifestEntry `json:"models"`
+}
+
+// ExportManifest parses and scans a workflow, resolves a source for every
+// model it references (present or missing) and writes the manifest to out
+func (m *ModelManager) ExportManifest(workflowPath, out string) error {
+	models, err := m.parser.ParseWorkflow(workflowPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse workflow: %w", err)
+	}
+
+	present, missing, err := m.scanner.ScanModels(models)
+	if err != nil {
+		return fmt.Errorf("failed to scan models: %w", err)
+	}
+	all := append(present, missing...)
+
+	// Prefer the recorded origin of models we downloaded ourselves, and
+	// only search for the rest
+	provenance := m.downloader.Provenance()
+	resolved := make(map[string]SearchResult)
+	var unresolved []Model
+	for _, model := range all {
+		if record, ok := provenance.Records[model.Key()]; ok {
+			resolved[model.Name] = SearchResult{
+				Name:        record.Name,
+				Source:      record.Source,
+				DownloadURL: record.DownloadURL,
+				Hash:        record.Hash,
+				Size:        record.Size,
+				ModelType:   record.Type,
+				ModelID:     record.ModelID,
+				VersionID:   record.VersionID,
+			}
+			continue
+		}
+		unresolved = append(unresolved, model)
+	}
+
+	if len(unresolved) > 0 {
+		fmt.Printf("Searching for %d models...\n", len(unresolved))
+		results, _ := m.searchModels(unresolved)
+		for name, result := range results {
+			resolved[name] = result
+		}
+	}
+
+	manifest := Manifest{Workflow: workflowPath, CreatedAt: time.Now()}
+	for _, model := range all {
+		entry := ManifestEntry{Model: model}
+		if result, ok := resolved[model.Name]; ok {
+			entry.Result = &result
+		} else {
+			fmt.Printf("  no source found for %s (%s)\n", model.Name, model.Type)
+		}
+		manifest.Models = append(manifest.Models, entry)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(out, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	fmt.Printf("Wrote manifest for %d models to %s\n", len(manifest.Models), out)
+	return nil
+}
+
+// LoadManifest reads a manifest written by ExportManifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// ImportManifest downloads the models listed in a manifest that aren't
+// present yet, using the recorded sources instead of searching, and
+// verifies their hashes
+func (m *ModelManager) ImportManifest(ctx context.Context, path string) error {
+	manifest, err := LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Importing %d models from %s\n", len(manifest.Models), path)
+
+	// Destinations are resolved against this machine's config, not the
+	// paths recorded by whoever exported the manifest
+	var models []Model
+	results := make(map[string]SearchResult)
+	for _, entry := range manifest.Models {
+		model := entry.Model
+		if _, err := ParseModelType(string(model.Type)); err != nil {
+			fmt.Printf("  %s: %v, skipping\n", model.Name, err)
+			continue
+		}
+		localPath, err := m.config.SafeModelPath(model.Type, model.Name)
+		if err != nil {
+			fmt.Printf("  %v, skipping\n", err)
+			continue
+		}
+		model.LocalPath = localPath
+		model.IsPresent = false
+
+		if entry.Result == nil {
+			fmt.Printf("  no source recorded for %s (%s), skipping\n", model.Name, model.Type)
+			continue
+		}
+		models = append(models, model)
+		results[model.Name] = *entry.Result
+	}
+
+	present, missing, err := m.scanner.ScanModels(models)
+	if err != nil {
+		return fmt.Errorf("failed to scan models: %w", err)
+	}
+	fmt.Printf("Present models: %d\n", len(present))
+	fmt.Printf("Missing models: %d\n", len(missing))
+	_, missing = m.applyForce(present, missing)
+
+	if len(missing) == 0 {
+		fmt.Println("\nAll models are present! No downloads needed.")
+		return nil
+	}
+
+	if err := m.confirmDownload(missing, results); err != nil {
+		return err
+	}
+
+	if err := m.downloadAndSummarize(ctx, missing, results); err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	// Downloads are checked against the recorded hashes as they finish
+	fmt.Println("\nAll downloads completed and verified!")
+	return nil
+}
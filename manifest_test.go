@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportManifestRejectsUnsafeEntries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("weights"))
+	}))
+	defer server.Close()
+
+	config := newTestConfig(t)
+	config.MinFileSize = nil
+	manager := newTestManager(config)
+
+	direct := &SearchResult{Name: "model.safetensors", Source: "direct", DownloadURL: server.URL + "/model.safetensors"}
+	entries := []ManifestEntry{
+		{Model: Model{Name: "good.safetensors", Type: ModelTypeCheckpoint}, Result: direct},
+		{Model: Model{Name: "../../../../outside.safetensors", Type: ModelTypeLora}, Result: direct},
+		{Model: Model{Name: `sub\..\..\..\escape.safetensors`, Type: ModelTypeLora}, Result: direct},
+		{Model: Model{Name: "/tmp/absolute.safetensors", Type: ModelTypeLora}, Result: direct},
+		{Model: Model{Name: "x.safetensors", Type: "bogus"}, Result: direct},
+	}
+	data, err := json.Marshal(Manifest{Models: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	writeFile(t, manifestPath, data)
+
+	if err := manager.ImportManifest(context.Background(), manifestPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fileExists(config.GetModelPath(ModelTypeCheckpoint, "good.safetensors")) {
+		t.Error("the safe entry wasn't imported")
+	}
+	for _, entry := range entries[1:] {
+		if target := config.GetModelPath(entry.Model.Type, entry.Model.Name); fileExists(target) {
+			t.Errorf("%s was written to %s", entry.Model.Name, target)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(config.ComfyUIPath, "models", "unknown")); err == nil {
+		t.Error("an unknown type was imported into models/unknown")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dirMappings collects repeated --map-dir type=path flags
+type dirMappings map[ModelType]string
+
+func (m dirMappings) String() string {
+	pairs := make([]string, 0, len(m))
+	for modelType, dir := range m {
+		pairs = append(pairs, string(modelType)+"="+dir)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set parses one type=path mapping
+func (m dirMappings) Set(value string) error {
+	name, dir, ok := strings.Cut(value, "=")
+	name, dir = strings.TrimSpace(name), strings.TrimSpace(dir)
+	if !ok || name == "" || dir == "" {
+		return fmt.Errorf("expected type=path, got %q", value)
+	}
+
+	modelType, err := ParseModelType(name)
+	if err != nil {
+		return fmt.Errorf("%w (known types: %s)", err, knownTypeNames())
+	}
+	m[modelType] = dir
+	return nil
+}
+
+// MapDirs overrides the directories of individual model types for a run,
+// taking precedence over both model_dirs and model_path_overrides.
+// Relative paths are under ComfyUIPath, as in model_dirs.
+func (c *Config) MapDirs(dirs map[ModelType]string) {
+	if c.ModelDirs == nil {
+		c.ModelDirs = make(map[string]string)
+	}
+	for modelType, dir := range dirs {
+		c.ModelDirs[string(modelType)] = dir
+		delete(c.ModelPathOverrides, string(modelType))
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ModelSpec describes a single model requested with --get
+type ModelSpec struct {
+	Source    string // "huggingface" or "civitai"
+	RepoID    string // HuggingFace repository, e.g. "owner/repo"
+	Filename  string // HuggingFace file path within the repository
+	Revision  string // HuggingFace revision, defaults to "main"
+	ModelID   int    // CivitAI model ID, from a model page URL
+	VersionID int    // CivitAI model version ID; 0 means the newest of ModelID
+	AIR       string // CivitAI AIR identifier, resolved instead of VersionID
+}
+
+// ParseModelSpec parses a HF repo/file spec, HF URL, CivitAI URL or AIR
+func ParseModelSpec(spec string) (*ModelSpec, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty model spec")
+	}
+
+	if isAIR(spec) {
+		return &ModelSpec{Source: "civitai", AIR: spec}, nil
+	}
+	if !strings.Contains(spec, "://") {
+		return parseHFRepoSpec(spec)
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	switch strings.TrimPrefix(u.Host, "www.") {
+	case "huggingface.co", "hf.co":
+		return parseHFURL(u)
+	case "civitai.com":
+		return parseCivitAIURL(u)
+	default:
+		return nil, fmt.Errorf("unsupported host: %s", u.Host)
+	}
+}
+
+// parseHFRepoSpec parses "owner/repo" or "owner/repo/path/to/file"
+func parseHFRepoSpec(spec string) (*ModelSpec, error) {
+	parts := strings.Split(strings.Trim(spec, "/"), "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("expected owner/repo[/file], got %q", spec)
+	}
+
+	return &ModelSpec{
+		Source:   "huggingface",
+		RepoID:   parts[0] + "/" + parts[1],
+		Filename: strings.Join(parts[2:], "/"),
+		Revision: "main",
+	}, nil
+}
+
+// parseHFURL parses repository, tree and resolve/blob URLs on huggingface.co
+func parseHFURL(u *url.URL) (*ModelSpec, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("not a HuggingFace repository URL: %s", u)
+	}
+
+	spec := &ModelSpec{
+		Source:   "huggingface",
+		RepoID:   parts[0] + "/" + parts[1],
+		Revision: "main",
+	}
+
+	// owner/repo/{resolve,blob}/<revision>/<path>, or tree/<revision>[/<dir>]
+	switch {
+	case len(parts) >= 5 && (parts[2] == "resolve" || parts[2] == "blob"):
+		spec.Revision = parts[3]
+		spec.Filename = strings.Join(parts[4:], "/")
+	case len(parts) >= 4 && parts[2] == "tree":
+		spec.Revision = parts[3]
+		spec.Filename = strings.Join(parts[4:], "/")
+	}
+
+	return spec, nil
+}
+
+// parseCivitAIURL parses CivitAI model pages, as copied from the browser,
+// and API download and model-version URLs
+func parseCivitAIURL(u *url.URL) (*ModelSpec, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	var idPart string
+	switch {
+	// /models/<modelID>[/<slug>][?modelVersionId=<versionID>]
+	case len(parts) >= 2 && parts[0] == "models":
+		return parseCivitAIModelPage(u, parts[1])
+	// /api/v1/models/<modelID>
+	case len(parts) == 4 && parts[0] == "api" && parts[1] == "v1" && parts[2] == "models":
+		return parseCivitAIModelPage(u, parts[3])
+	// /api/download/models/<versionID>
+	case len(parts) == 4 && parts[0] == "api" && parts[1] == "download" && parts[2] == "models":
+		idPart = parts[3]
+	// /api/v1/model-versions/<versionID>
+	case len(parts) == 4 && parts[0] == "api" && parts[2] == "model-versions":
+		idPart = parts[3]
+	default:
+		return nil, fmt.Errorf("unsupported CivitAI URL: %s", u)
+	}
+
+	versionID, err := strconv.Atoi(idPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CivitAI version ID %q", idPart)
+	}
+
+	return &ModelSpec{Source: "civitai", VersionID: versionID}, nil
+}
+
+// parseCivitAIModelPage parses a model page's model ID and its optional
+// modelVersionId query parameter
+func parseCivitAIModelPage(u *url.URL, idPart string) (*ModelSpec, error) {
+	modelID, err := strconv.Atoi(idPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CivitAI model ID %q", idPart)
+	}
+
+	spec := &ModelSpec{Source: "civitai", ModelID: modelID}
+	if version := u.Query().Get("modelVersionId"); version != "" {
+		if spec.VersionID, err = strconv.Atoi(version); err != nil {
+			return nil, fmt.Errorf("invalid CivitAI version ID %q", version)
+		}
+	}
+	return spec, nil
+}
+
+// GetModel resolves a single model spec and downloads it
+func (m *ModelManager) GetModel(ctx context.Context, spec string, modelType ModelType) error {
+	parsed, err := ParseModelSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	result, err := m.resolveSpec(parsed, modelType)
+	if err != nil {
+		return err
+	}
+
+	if result.ModelType == "" {
+		result.ModelType = guessModelType(result.Name)
+		fmt.Printf("No --type given, assuming %s\n", result.ModelType)
+	}
+
+	filename := path.Base(result.Name)
+	model := Model{
+		Name:      filename,
+		Type:      result.ModelType,
+		LocalPath: m.config.GetModelPath(result.ModelType, filename),
+	}
+
+	if fileExists(model.LocalPath) && !m.force {
+		fmt.Printf("%s already exists at %s\n", model.Name, model.LocalPath)
+		return nil
+	}
+
+	fmt.Printf("Downloading %s from %s to %s\n", model.Name, result.Source, model.LocalPath)
+	_, err = m.downloader.DownloadModels(ctx, []Model{model}, map[string]SearchResult{model.Name: *result})
+	return err
+}
+
+// resolveSpec turns a parsed spec into a downloadable SearchResult
+func (m *ModelManager) resolveSpec(spec *ModelSpec, modelType ModelType) (*SearchResult, error) {
+	switch spec.Source {
+	case "huggingface":
+		return m.resolveHFSpec(spec, modelType)
+	case "civitai":
+		if spec.AIR != "" {
+			return m.downloader.civitClient.GetModelByAIR(spec.AIR, modelType)
+		}
+		if spec.VersionID == 0 {
+			versionID, err := m.downloader.civitClient.LatestVersionID(spec.ModelID)
+			if err != nil {
+				return nil, err
+			}
+			spec.VersionID = versionID
+		}
+		version, err := m.downloader.civitClient.GetModelVersion(spec.VersionID)
+		if err != nil {
+			return nil, err
+		}
+		files := m.downloader.civitClient.VersionFiles(version, modelType)
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no downloadable files in CivitAI version %d", spec.VersionID)
+		}
+		return pickResult(files)
+	default:
+		return nil, fmt.Errorf("unknown source: %s", spec.Source)
+	}
+}
+
+// resolveHFSpec resolves a HuggingFace repo or file spec
+func (m *ModelManager) resolveHFSpec(spec *ModelSpec, modelType ModelType) (*SearchResult, error) {
+	files, err := m.downloader.hfClient.ListModelFiles(spec.RepoID, modelType)
+	if err != nil && spec.Filename == "" {
+		return nil, fmt.Errorf("failed to list %s: %w", spec.RepoID, err)
+	}
+
+	if spec.Filename == "" {
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no model files found in %s", spec.RepoID)
+		}
+		return pickResult(files)
+	}
+
+	// Prefer the listing entry so size and hash are known
+	for _, file := range files {
+		if file.Name == spec.Filename {
+			return &file, nil
+		}
+	}
+
+	return &SearchResult{
+		Name:        spec.Filename,
+		Source:      "huggingface",
+		DownloadURL: m.downloader.hfClient.resolveURL(spec.RepoID, spec.Revision, spec.Filename),
+		ModelType:   modelType,
+	}, nil
+}
+
+// pickResult returns the only result, or asks the user to choose one
+func pickResult(results []SearchResult) (*SearchResult, error) {
+	if len(results) == 1 {
+		return &results[0], nil
+	}
+
+	fmt.Println("Multiple model files found:")
+	for i, result := range results {
+		fmt.Printf("  %d) %s (%.2f MB)\n", i+1, result.Name, float64(result.Size)/(1024*1024))
+	}
+	fmt.Print("Select a file: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read selection: %w", err)
+	}
+
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(results) {
+		return nil, fmt.Errorf("invalid selection: %q", strings.TrimSpace(line))
+	}
+
+	return &results[choice-1], nil
+}
+
+// guessModelType guesses a model type from a filename
+func guessModelType(name string) ModelType {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "lora"):
+		return ModelTypeLora
+	case strings.Contains(lower, "vae"):
+		return ModelTypeVAE
+	case strings.Contains(lower, "controlnet"):
+		return ModelTypeControlNet
+	default:
+		return ModelTypeCheckpoint
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ModelFilter selects installed models for --list
+type ModelFilter struct {
+	Name    string    // case-insensitive substring of the model name
+	Type    ModelType // "" lists every type
+	MinSize int64
+	MaxSize int64 // 0 means no upper bound
+}
+
+// Matches reports whether a scanned model passes the filter
+func (f ModelFilter) Matches(model Model) bool {
+	if f.Type != "" && model.Type != f.Type {
+		return false
+	}
+	if f.Name != "" && !strings.Contains(strings.ToLower(model.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	if model.Size < f.MinSize {
+		return false
+	}
+	return f.MaxSize == 0 || model.Size <= f.MaxSize
+}
+
+// ParseSize parses a size such as "500MB", "1.5G" or "2048", using 1024
+// multiples as formatBytes does
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			s = s[:n-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 500MB or 2GB", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
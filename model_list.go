@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ModelListEntry is one entry of a ComfyUI-Manager model-list.json
+type ModelListEntry struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Base     string `json:"base"`
+	SavePath string `json:"save_path"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	Size     string `json:"size"`
+}
+
+// ModelList resolves model filenames to curated download URLs
+type ModelList struct {
+	entries map[string]ModelListEntry // keyed by lowercased filename
+}
+
+// LoadModelList loads a ComfyUI-Manager model list from a file or URL
+func LoadModelList(location string) (*ModelList, error) {
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = fetchModelList(location)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model list: %w", err)
+	}
+
+	var raw struct {
+		Models []ModelListEntry `json:"models"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+
+	list := &ModelList{entries: make(map[string]ModelListEntry)}
+	for _, entry := range raw.Models {
+		if entry.Filename == "" || entry.URL == "" {
+			continue
+		}
+		list.entries[strings.ToLower(entry.Filename)] = entry
+	}
+
+	return list, nil
+}
+
+// fetchModelList downloads a model list over HTTP
+func fetchModelList(listURL string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Get(listURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPStatusError("model list fetch failed", resp, false)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Lookup returns a SearchResult for a model listed by filename
+func (l *ModelList) Lookup(model Model) *SearchResult {
+	if l == nil {
+		return nil
+	}
+
+	entry, ok := l.entries[strings.ToLower(path.Base(normalizeModelName(model.Name)))]
+	if !ok {
+		return nil
+	}
+
+	source := sourceForURL(entry.URL)
+	if source == "" {
+		return nil // Not an http(s) download
+	}
+
+	return &SearchResult{
+		Name:        entry.Filename,
+		Source:      source,
+		DownloadURL: entry.URL,
+		ModelType:   model.Type,
+	}
+}
+
+// sourceForURL returns the download source that handles a URL's host
+func sourceForURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.TrimPrefix(u.Host, "www.") {
+	case "huggingface.co", "hf.co":
+		return "huggingface"
+	case "civitai.com":
+		return "civitai"
+	default:
+		return directSource(rawURL)
+	}
+}
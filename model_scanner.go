@@ -5,32 +5,58 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"lukechampine.com/blake3"
 )
 
 // ModelScanner handles checking for existing models
 type ModelScanner struct {
 	config *Config
+	server *ComfyUIServer // nil unless comfyui_url is set
+	fuzzy  bool           // --fuzzy: match names by prefix or substring
+	anyDir bool           // --exclude-present-in-any-dir: look in every model directory
 }
 
 // NewModelScanner creates a new model scanner
 func NewModelScanner(config *Config) *ModelScanner {
-	return &ModelScanner{config: config}
+	return &ModelScanner{config: config, server: NewComfyUIServer(config)}
 }
 
-// ScanModels checks which models from the list are present locally
+// ScanModels checks which models from the list are present locally. It
+// only probes expected paths, and walks the model directories only for
+// --exclude-present-in-any-dir.
 func (s *ModelScanner) ScanModels(models []Model) ([]Model, []Model, error) {
 	var present, missing []Model
+	cache := newDirCache()
 
 	for _, model := range models {
-		exists, err := s.checkModelExists(model)
+		if !s.config.TypeFilter.Allows(model.Type) {
+			continue
+		}
+
+		exists, err := s.checkModelExists(&model, cache)
 		if err != nil {
 			return nil, nil, fmt.Errorf("error checking model %s: %w", model.Name, err)
 		}
 
+		// A running ComfyUI knows what it can actually load, including
+		// directories only its own extra_model_paths.yaml mentions
+		if known, ok := s.server.Knows(model.Type, model.Name); ok {
+			switch {
+			case known:
+				exists = true
+			case exists:
+				log.Printf("%s is at %s but ComfyUI doesn't list it; refresh ComfyUI or check its model paths\n",
+					model.Name, model.LocalPath)
+			}
+		}
+
 		model.IsPresent = exists
 		if exists {
 			present = append(present, model)
@@ -42,38 +68,111 @@ func (s *ModelScanner) ScanModels(models []Model) ([]Model, []Model, error) {
 	return present, missing, nil
 }
 
-// checkModelExists checks if a model file exists locally
-func (s *ModelScanner) checkModelExists(model Model) (bool, error) {
-	// First check the exact path
-	if fileExists(model.LocalPath) {
+// checkModelExists checks if a model file exists locally in any of the
+// search directories for its type, updating LocalPath when found
+func (s *ModelScanner) checkModelExists(model *Model, cache *dirCache) (bool, error) {
+	// First check the expected download location
+	if path, ok := probeModelPath(model.LocalPath, cache); ok {
+		model.LocalPath = path
 		return true, nil
 	}
 
-	// Check without extension
-	baseNameWithoutExt := strings.TrimSuffix(model.Name, filepath.Ext(model.Name))
-	dirPath := filepath.Dir(model.LocalPath)
+	// Then the ComfyUI and extra directories configured for this type,
+	// which differ from the download location when --dest is set
+	relPath := filepath.FromSlash(normalizeModelName(model.Name))
+	for _, dir := range s.config.SearchDirs(model.Type) {
+		candidate := filepath.Join(dir, relPath)
+		if candidate == model.LocalPath {
+			continue
+		}
+		if path, ok := probeModelPath(candidate, cache); ok {
+			model.LocalPath = path
+			return true, nil
+		}
+	}
+
+	if s.checkAlias(model, cache) {
+		return true, nil
+	}
 
-	// Common model extensions
-	extensions := []string{
-		".safetensors", ".ckpt", ".pt", ".pth", ".bin",
-		".yaml", ".json", // for configs
+	if s.anyDir && s.findInAnyDir(model, cache) {
+		return true, nil
 	}
 
-	// Try different extensions
-	for _, ext := range extensions {
-		testPath := filepath.Join(dirPath, baseNameWithoutExt+ext)
-		if fileExists(testPath) {
-			model.LocalPath = testPath
-			return true, nil
+	if s.fuzzy {
+		return s.fuzzyMatch(model, cache), nil
+	}
+	return false, nil
+}
+
+// fuzzyMatch looks for a file whose name only partly matches the model,
+// e.g. "realvisxl" for realvisxlV40.safetensors, updating LocalPath
+func (s *ModelScanner) fuzzyMatch(model *Model, cache *dirCache) bool {
+	relPath := filepath.FromSlash(normalizeModelName(model.Name))
+	candidates := []string{model.LocalPath}
+	for _, dir := range s.config.SearchDirs(model.Type) {
+		candidates = append(candidates, filepath.Join(dir, relPath))
+	}
+
+	for _, candidate := range candidates {
+		path, ok, err := cache.fuzzyFind(candidate)
+		if err != nil {
+			log.Printf("Fuzzy match skipped: %v\n", err)
+			return false
+		}
+		if ok {
+			fmt.Printf("Fuzzy matched %s to %s\n", model.Name, path)
+			model.LocalPath = path
+			return true
+		}
+	}
+	return false
+}
+
+// probeExtensions are the extensions a model reference may resolve to
+var probeExtensions = []string{
+	".safetensors", ".sft", ".gguf", ".ckpt", ".pt", ".pth", ".bin",
+	".yaml", ".json", // for configs
+}
+
+// hasModelExtension reports whether a name ends in a known model extension
+func hasModelExtension(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, known := range probeExtensions {
+		if ext == known {
+			return true
+		}
+	}
+	return false
+}
+
+// probeModelPath checks a candidate path and, when the name has no model
+// extension, its extension variants. A name with an extension only
+// matches that extension, so model.safetensors never resolves to a
+// model.ckpt beside it that ComfyUI would not load for the workflow.
+func probeModelPath(localPath string, cache *dirCache) (string, bool) {
+	// First check the exact path, allowing case and whitespace differences
+	if path, ok := cache.findFile(localPath); ok {
+		return path, true
+	}
+
+	// Names like "easynegative" or "v1.5-neg" have no model extension and
+	// may be any of the formats; use the local path so subfolders aren't
+	// doubled
+	if !hasModelExtension(localPath) {
+		for _, ext := range probeExtensions {
+			if path, ok := cache.findFile(localPath + ext); ok {
+				return path, true
+			}
 		}
 	}
 
 	// Check if it's a directory (some models are directories)
-	if dirExists(model.LocalPath) {
-		return true, nil
+	if path, ok := cache.findDir(localPath); ok {
+		return path, true
 	}
 
-	return false, nil
+	return "", false
 }
 
 // CalculateModelHash calculates the hash of a model file
@@ -100,12 +199,54 @@ func (s *ModelScanner) CalculateModelHash(path string, hashType string) (string,
 			return "", err
 		}
 		return hex.EncodeToString(h.Sum(nil)), nil
+	case "blake3":
+		// CivitAI lists a BLAKE3 hash for each file alongside SHA256
+		return hashReader(blake3.New(32, nil), file)
 	default:
 		// For large files, calculate a quick hash of first and last MB
 		return s.calculateQuickHash(file)
 	}
 }
 
+// sha256File returns the hex SHA256 of a file
+func sha256File(path string) (string, error) {
+	return hashFile(sha256.New(), path)
+}
+
+// blake3File returns the hex BLAKE3 of a file, which is several times
+// faster to compute than its SHA256
+func blake3File(path string) (string, error) {
+	return hashFile(blake3.New(32, nil), path)
+}
+
+// hashFile returns the hex digest of a file
+func hashFile(h hash.Hash, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return hashReader(h, file)
+}
+
+// hashReader returns the hex digest of everything r yields. It reads 1MB
+// at a time: BLAKE3 hashes large writes in parallel, while io.Copy from a
+// file hands it 32KB.
+func hashReader(h hash.Hash, r io.Reader) (string, error) {
+	buf := make([]byte, 1024*1024)
+	for {
+		n, err := io.ReadFull(r, buf)
+		h.Write(buf[:n])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // calculateQuickHash calculates a quick hash for large files
 func (s *ModelScanner) calculateQuickHash(file *os.File) (string, error) {
 	hasher := sha256.New()
@@ -140,15 +281,26 @@ func (s *ModelScanner) calculateQuickHash(file *os.File) (string, error) {
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-// ScanDirectory scans a directory for all model files
+// ScanDirectory scans all search directories of a type for model files
 func (s *ModelScanner) ScanDirectory(modelType ModelType) ([]Model, error) {
-	dir, exists := s.config.ModelDirs[string(modelType)]
-	if !exists {
+	if _, exists := s.config.ModelDirs[string(modelType)]; !exists {
 		return nil, fmt.Errorf("unknown model type: %s", modelType)
 	}
 
-	fullPath := filepath.Join(s.config.ComfyUIPath, dir)
+	var models []Model
+	for _, dir := range s.config.SearchDirs(modelType) {
+		dirModels, err := s.scanDir(dir, modelType)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, dirModels...)
+	}
+
+	return models, nil
+}
 
+// scanDir walks a single directory for model files
+func (s *ModelScanner) scanDir(fullPath string, modelType ModelType) ([]Model, error) {
 	var models []Model
 
 	err := filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
@@ -164,6 +316,8 @@ func (s *ModelScanner) ScanDirectory(modelType ModelType) ([]Model, error) {
 		ext := strings.ToLower(filepath.Ext(path))
 		modelExts := map[string]bool{
 			".safetensors": true,
+			".sft":         true,
+			".gguf":        true,
 			".ckpt":        true,
 			".pt":          true,
 			".pth":         true,
@@ -173,7 +327,7 @@ func (s *ModelScanner) ScanDirectory(modelType ModelType) ([]Model, error) {
 		if modelExts[ext] {
 			relPath, _ := filepath.Rel(fullPath, path)
 			models = append(models, Model{
-				Name:      relPath,
+				Name:      filepath.ToSlash(relPath),
 				Type:      modelType,
 				LocalPath: path,
 				Size:      info.Size(),
@@ -208,6 +362,20 @@ func (s *ModelScanner) GetModelInfo(model Model) (Model, error) {
 		}
 	}
 
+	// Read what the file says about itself
+	if strings.EqualFold(filepath.Ext(model.LocalPath), ".safetensors") {
+		if header, err := ReadSafetensorsHeader(model.LocalPath); err == nil {
+			model.BaseModel = header.BaseModel()
+			model.Architecture = header.Architecture()
+			model.DetectedType = header.DetectType()
+
+			if model.DetectedType != "" && model.DetectedType != model.Type {
+				log.Printf("Warning: %s is in %s but looks like %s\n",
+					model.Name, model.Type, model.DetectedType)
+			}
+		}
+	}
+
 	return model, nil
 }
 
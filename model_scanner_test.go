@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSourceHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.safetensors")
+	writeFile(t, path, []byte("weights"))
+	sha, err := sha256File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blake, err := blake3File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		result SearchResult
+		want   error
+	}{
+		{"blake3", SearchResult{BLAKE3: blake}, nil},
+		{"sha256", SearchResult{Hash: sha}, nil},
+		{"blake3 preferred", SearchResult{Hash: "wrong", BLAKE3: blake}, nil},
+		{"blake3 mismatch", SearchResult{Hash: sha, BLAKE3: sha}, ErrHashMismatch},
+		{"sha256 mismatch", SearchResult{Hash: blake}, ErrHashMismatch},
+		{"no hash", SearchResult{}, nil},
+	}
+	for _, tt := range tests {
+		if err := checkSourceHash(path, tt.result); !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkSourceHash = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCheckModelExistsExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		request string
+		want    string // file found, "" for missing
+	}{
+		{"safetensors of both", []string{"x.ckpt", "x.safetensors"}, "x.safetensors", "x.safetensors"},
+		{"ckpt of both", []string{"x.ckpt", "x.safetensors"}, "x.ckpt", "x.ckpt"},
+		{"other extension only", []string{"x.ckpt"}, "x.safetensors", ""},
+		{"case differs", []string{"X.SafeTensors"}, "x.safetensors", "X.SafeTensors"},
+		{"no extension, both", []string{"x.ckpt", "x.safetensors"}, "x", "x.safetensors"},
+		{"no extension, ckpt", []string{"x.ckpt"}, "x", "x.ckpt"},
+		{"no extension, none", nil, "x", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(t)
+			dir := config.GetModelDir(ModelTypeCheckpoint)
+			for _, file := range tt.files {
+				writeFile(t, filepath.Join(dir, file), []byte("weights"))
+			}
+
+			model := Model{
+				Name:      tt.request,
+				Type:      ModelTypeCheckpoint,
+				LocalPath: config.GetModelPath(ModelTypeCheckpoint, tt.request),
+			}
+			found, err := NewModelScanner(config).checkModelExists(&model, newDirCache())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case tt.want == "" && found:
+				t.Errorf("%s reported present as %s", tt.request, model.LocalPath)
+			case tt.want != "" && !found:
+				t.Errorf("%s reported missing", tt.request)
+			case found && filepath.Base(model.LocalPath) != tt.want:
+				t.Errorf("%s found as %s, want %s", tt.request, filepath.Base(model.LocalPath), tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkCalculateModelHash(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "model.safetensors")
+	data := make([]byte, 64*1024*1024)
+	rand.Read(data)
+	writeFile(b, path, data)
+	scanner := NewModelScanner(DefaultConfig())
+
+	for _, hashType := range []string{"sha256", "blake3"} {
+		b.Run(hashType, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := scanner.CalculateModelHash(path, hashType); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrAllFiltered is returned by a search that found candidates but rejected
+// all of them (wrong type, unsafe file, blocked format, ...)
+var ErrAllFiltered = errors.New("all candidates were filtered out")
+
+// SearchAttempt records the outcome of trying one source for a model
+type SearchAttempt struct {
+	Source  string
+	Outcome string
+}
+
+// SearchReport explains how a model was, or wasn't, resolved
+type SearchReport struct {
+	Model    Model
+	Attempts []SearchAttempt
+	FoundBy  string // the resolver that found the model, if any
+}
+
+// add records the outcome for a source
+func (r *SearchReport) add(source, format string, args ...interface{}) {
+	r.Attempts = append(r.Attempts, SearchAttempt{Source: source, Outcome: fmt.Sprintf(format, args...)})
+}
+
+// searchModels searches for models on HuggingFace and CivitAI, returning
+// the results found and a report for every model searched
+func (m *ModelManager) searchModels(models []Model) (map[string]SearchResult, map[string]SearchReport) {
+	results := make(map[string]SearchResult)
+	reports := make(map[string]SearchReport)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	// Search concurrently, bounded to be gentle on the APIs
+	limit := m.config.SearchWorkers
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+
+	for _, model := range models {
+		wg.Add(1)
+		go func(model Model) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result, report := m.searchModel(model)
+			mu.Lock()
+			if result != nil {
+				results[model.Name] = *result
+			}
+			reports[model.Name] = report
+			mu.Unlock()
+		}(model)
+	}
+
+	wg.Wait()
+	return results, reports
+}
+
+// searchModel tries each resolver in turn until one finds the model
+func (m *ModelManager) searchModel(model Model) (*SearchResult, SearchReport) {
+	candidates, report := m.SearchCandidates(model, 1)
+	if len(candidates) == 0 {
+		return nil, report
+	}
+	return &candidates[0], report
+}
+
+// SearchCandidates returns up to limit candidates for a model, best first:
+// each resolver's matches in resolver order, skipping files already listed
+// under the same hash or name. A limit of 0 uses the configured
+// search_limit. FoundBy names the resolver of the first candidate.
+func (m *ModelManager) SearchCandidates(model Model, limit int) ([]SearchResult, SearchReport) {
+	if limit <= 0 {
+		limit = m.config.SearchLimit
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	report := SearchReport{Model: model}
+
+	// An alias points the search at a URL or at the model's canonical name
+	query := model
+	if target, ok := m.config.Alias(model.Name); ok {
+		if isURL(target) {
+			resolver := &modelURLResolver{urls: map[string]string{model.Name: target}, civitai: m.downloader.civitClient}
+			result, err := resolver.Search(model)
+			if err != nil {
+				report.add("alias", "%v", err)
+				return nil, report
+			}
+			report.add("alias", "found %s", target)
+			report.FoundBy = "alias"
+			return []SearchResult{*result}, report
+		}
+		report.add("alias", "searching as %s", target)
+		query.Name = target
+	}
+
+	var candidates []SearchResult
+	seen := make(map[string]bool)
+	for _, resolver := range m.resolversFor(model.Type) {
+		results, err := resolverCandidates(resolver, query)
+		if err != nil {
+			report.add(resolver.Name(), "%v", err)
+			continue
+		}
+
+		added := 0
+		for _, result := range results {
+			if len(candidates) == limit {
+				break
+			}
+			if markSeen(seen, result) {
+				continue
+			}
+			candidates = append(candidates, result)
+			added++
+		}
+
+		switch {
+		case len(results) == 0:
+			report.add(resolver.Name(), "no match for %q", cleanModelName(query.Name))
+		case added == 0:
+			report.add(resolver.Name(), "found %s, already a candidate", results[0].Name)
+		default:
+			report.add(resolver.Name(), "found %s", results[0].Name)
+			if report.FoundBy == "" {
+				report.FoundBy = resolver.Name()
+			}
+		}
+		if len(candidates) == limit {
+			break
+		}
+	}
+
+	return candidates, report
+}
+
+// resolverCandidates returns every match a resolver has for a model;
+// resolvers that only pick one give that
+func resolverCandidates(resolver Resolver, model Model) ([]SearchResult, error) {
+	if multi, ok := resolver.(CandidateResolver); ok {
+		return multi.SearchAll(model)
+	}
+	result, err := resolver.Search(model)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	return []SearchResult{*result}, nil
+}
+
+// markSeen records a search result's file by name and, when known, by
+// hash, reporting whether either was already seen
+func markSeen(seen map[string]bool, result SearchResult) bool {
+	keys := []string{"name:" + strings.ToLower(path.Base(result.Name))}
+	if result.Hash != "" {
+		keys = append(keys, "hash:"+strings.ToLower(result.Hash))
+	}
+
+	duplicate := false
+	for _, key := range keys {
+		duplicate = duplicate || seen[key]
+		seen[key] = true
+	}
+	return duplicate
+}
+
+// Search queries the model sources and prints every result without downloading.
+// An empty source searches all sources in the configured priority order.
+func (m *ModelManager) Search(query string, modelType ModelType, source string) error {
+	sources := m.config.SourcesFor(modelType)
+	if source != "" {
+		sources = []string{source}
+	}
+
+	total := 0
+	for _, source := range sources {
+		var results []SearchResult
+		var err error
+
+		switch source {
+		case "huggingface":
+			results, err = m.downloader.hfClient.SearchModels(query, modelType)
+		case "civitai":
+			results, err = m.downloader.civitClient.SearchModels(query, modelType)
+		default:
+			return fmt.Errorf("unknown source: %s", source)
+		}
+
+		if err != nil {
+			log.Printf("%s search failed: %v\n", source, err)
+			continue
+		}
+
+		fmt.Printf("\n%s: %d results\n", source, len(results))
+		for i, result := range results {
+			fmt.Printf("  %d. %s (%.2f MB)", i+1, result.Name, float64(result.Size)/(1024*1024))
+			if result.Creator != "" {
+				fmt.Printf(" by %s", result.Creator)
+			}
+			fmt.Printf("\n     %s\n", result.DownloadURL)
+		}
+		total += len(results)
+	}
+
+	if total == 0 {
+		fmt.Println("\nNo results found.")
+	}
+
+	return nil
+}
+
+// quantSuffix matches a trailing quantization tag such as "-bnb-nf4",
+// "_fp8_e4m3fn", "-Q4_K_M" or "-int8"
+var quantSuffix = regexp.MustCompile(`(?i)[-_.]((?:bnb[-_])?(?:nf4|fp4)|fp8(?:[-_]e4m3fn|[-_]e5m2)?(?:[-_]scaled)?|int[48]|i?q[1-8](?:_k)?(?:_[a-z0-9]{1,2})?)$`)
+
+// splitQuant splits a quantization tag off the end of a model name,
+// returning the name without it and the tag with its separator
+func splitQuant(name string) (string, string) {
+	loc := quantSuffix.FindStringIndex(name)
+	if loc == nil {
+		return name, ""
+	}
+	return name[:loc[0]], name[loc[0]:]
+}
+
+// quantKey reduces a quantization tag to compare, e.g. "-Q4_K_M" -> "q4km"
+func quantKey(tag string) string {
+	return strings.NewReplacer("-", "", "_", "", ".", "").Replace(strings.ToLower(tag))
+}
+
+// preferQuant moves the first result with the same quantization as the
+// model's name to the front, since repositories often publish every
+// quantization of a model side by side
+func preferQuant(name string, results []SearchResult) []SearchResult {
+	base := path.Base(normalizeModelName(name))
+	_, quant := splitQuant(strings.TrimSuffix(base, filepath.Ext(base)))
+	if quant == "" || len(results) == 0 {
+		return results
+	}
+
+	for i, result := range results {
+		file := path.Base(result.Name)
+		if _, tag := splitQuant(strings.TrimSuffix(file, path.Ext(file))); quantKey(tag) == quantKey(quant) {
+			match := results[i]
+			copy(results[1:i+1], results[:i])
+			results[0] = match
+			return results
+		}
+	}
+
+	log.Printf("No %s variant of %s found; the best match is %s\n", strings.Trim(quant, "-_."), base, results[0].Name)
+	return results
+}
+
+// cleanModelName cleans up a model name for searching. A quantization tag
+// is kept, since a search for the full-precision name finds the wrong file.
+func cleanModelName(name string) string {
+	// Search by file name only, without any subfolder
+	name = path.Base(normalizeModelName(name))
+
+	// Remove file extension
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	// Remove common suffixes, from before the quantization tag if any
+	// (e.g. "model_pruned-fp8" searches as "model-fp8")
+	name, quant := splitQuant(name)
+	suffixes := []string{"_fp16", "_fp32", "-fp16", "-fp32", "_pruned", "-pruned"}
+	for _, suffix := range suffixes {
+		name = strings.TrimSuffix(name, suffix)
+	}
+
+	return name + quant
+}
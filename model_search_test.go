@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestSplitQuant(t *testing.T) {
+	tests := []struct {
+		name, base, quant string
+	}{
+		{"flux1-dev-bnb-nf4", "flux1-dev", "-bnb-nf4"},
+		{"flux1-dev-fp8", "flux1-dev", "-fp8"},
+		{"t5xxl_fp8_e4m3fn", "t5xxl", "_fp8_e4m3fn"},
+		{"t5xxl_fp8_e4m3fn_scaled", "t5xxl", "_fp8_e4m3fn_scaled"},
+		{"model-int8", "model", "-int8"},
+		{"flux1-dev-Q4_K_M", "flux1-dev", "-Q4_K_M"},
+		{"wan2.1-i2v-Q8_0", "wan2.1-i2v", "-Q8_0"},
+		{"flux1-dev-IQ4_XS", "flux1-dev", "-IQ4_XS"},
+
+		// Version numbers and precision suffixes aren't quantization
+		{"sd_xl_base_1.0", "sd_xl_base_1.0", ""},
+		{"sd_xl_base_1.0_fp16", "sd_xl_base_1.0_fp16", ""},
+		{"v1-5-pruned-emaonly", "v1-5-pruned-emaonly", ""},
+		{"control_v11p_sd15_canny", "control_v11p_sd15_canny", ""},
+		{"qwen_2.5_vl_7b", "qwen_2.5_vl_7b", ""},
+		{"model_q10", "model_q10", ""},
+		{"quantized", "quantized", ""},
+	}
+
+	for _, tt := range tests {
+		base, quant := splitQuant(tt.name)
+		if base != tt.base || quant != tt.quant {
+			t.Errorf("splitQuant(%q) = %q, %q; want %q, %q", tt.name, base, quant, tt.base, tt.quant)
+		}
+	}
+}
+
+func TestCleanModelName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"flux1-dev-bnb-nf4.safetensors", "flux1-dev-bnb-nf4"},
+		{"t5xxl_fp8_e4m3fn_scaled.safetensors", "t5xxl_fp8_e4m3fn_scaled"},
+		{"flux1-dev-Q4_K_M.gguf", "flux1-dev-Q4_K_M"},
+		{"model_pruned-fp8.safetensors", "model-fp8"},
+		{"sd_xl_base_1.0_fp16.safetensors", "sd_xl_base_1.0"},
+		{"sd_xl_base_1.0.safetensors", "sd_xl_base_1.0"},
+		{"v1-5-pruned-emaonly.ckpt", "v1-5-pruned-emaonly"},
+		{"dreamshaper_8_pruned.safetensors", "dreamshaper_8"},
+		{"SDXL/juggernautXL_v9.safetensors", "juggernautXL_v9"},
+		{`loras\detail_tweaker.safetensors`, "detail_tweaker"},
+	}
+
+	for _, tt := range tests {
+		if got := cleanModelName(tt.name); got != tt.want {
+			t.Errorf("cleanModelName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
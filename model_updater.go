@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ModelUpdate describes a newer CivitAI version of a downloaded model
+type ModelUpdate struct {
+	Record ProvenanceRecord
+	Latest SearchResult
+}
+
+// CheckUpdates compares every CivitAI-sourced download against the newest
+// version of its model
+func (m *ModelManager) CheckUpdates() ([]ModelUpdate, error) {
+	var updates []ModelUpdate
+
+	records := m.downloader.Provenance().List()
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+
+	for _, record := range records {
+		if record.Source != "civitai" || record.ModelID == 0 {
+			continue
+		}
+		if !fileExists(record.LocalPath) {
+			continue // Only update models that are still installed
+		}
+
+		civitModel, err := m.downloader.civitClient.GetModel(record.ModelID)
+		if err != nil {
+			fmt.Printf("  %s: failed to check for updates: %v\n", record.Name, err)
+			continue
+		}
+		if len(civitModel.ModelVersions) == 0 {
+			continue
+		}
+
+		// CivitAI lists versions newest first
+		latest := civitModel.ModelVersions[0]
+		if latest.ID == record.VersionID {
+			continue
+		}
+
+		file := m.downloader.civitClient.PrimaryFile(&latest, record.Type)
+		if file == nil {
+			continue
+		}
+
+		updates = append(updates, ModelUpdate{Record: record, Latest: *file})
+	}
+
+	return updates, nil
+}
+
+// UpdateModels reports available updates and, when apply is set, downloads
+// them in place, optionally keeping the previous file as <name>.bak
+func (m *ModelManager) UpdateModels(ctx context.Context, apply bool, keepBackup bool) error {
+	fmt.Println("Checking CivitAI models for updates...")
+
+	updates, err := m.CheckUpdates()
+	if err != nil {
+		return err
+	}
+
+	if len(updates) == 0 {
+		fmt.Println("All models are up to date.")
+		return nil
+	}
+
+	fmt.Printf("\n%d updates available:\n", len(updates))
+	for _, update := range updates {
+		fmt.Printf("  - %s: version %d -> %d (%s, %.2f MB)\n",
+			update.Record.Name, update.Record.VersionID, update.Latest.VersionID,
+			update.Latest.Name, float64(update.Latest.Size)/(1024*1024))
+	}
+
+	if !apply {
+		fmt.Println("\nDry run; pass --apply to download these updates.")
+		return nil
+	}
+
+	for _, update := range updates {
+		if err := m.applyUpdate(ctx, update, keepBackup); err != nil {
+			return fmt.Errorf("failed to update %s: %w", update.Record.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyUpdate downloads the newer version over the installed file
+func (m *ModelManager) applyUpdate(ctx context.Context, update ModelUpdate, keepBackup bool) error {
+	// Keep the workflow-visible name so existing workflows keep loading it
+	model := Model{
+		Name:      update.Record.Name,
+		Type:      update.Record.Type,
+		LocalPath: update.Record.LocalPath,
+	}
+
+	backupPath := model.LocalPath + ".bak"
+	if keepBackup {
+		if err := os.Rename(model.LocalPath, backupPath); err != nil {
+			return fmt.Errorf("failed to back up old version: %w", err)
+		}
+	}
+
+	_, err := m.downloader.DownloadModels(ctx, []Model{model}, map[string]SearchResult{model.Name: update.Latest})
+	if err != nil && keepBackup {
+		// Put the old version back so the model stays usable
+		os.Rename(backupPath, model.LocalPath)
+	}
+
+	return err
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PartialDownload is a .tmp file left by a download that never finished
+type PartialDownload struct {
+	Path     string    `json:"path"`
+	Target   string    `json:"target,omitempty"` // the model it would become, if known
+	Type     ModelType `json:"type,omitempty"`
+	Size     int64     `json:"size"`
+	Expected int64     `json:"expected,omitempty"` // from the saved batch, if known
+	Active   bool      `json:"active"`             // another process is downloading it
+	Resume   bool      `json:"resumable"`          // --resume-batch will continue it
+}
+
+// Oversized reports whether the partial file is already larger than the
+// model, e.g. after a server ignored a range request; it can't resume
+func (p PartialDownload) Oversized() bool {
+	return p.Expected > 0 && p.Size > p.Expected
+}
+
+// FindPartialDownloads lists the .tmp files in every model directory and
+// TempDir whose model isn't there, matching them to the saved batch for
+// their expected size
+func (m *ModelManager) FindPartialDownloads() ([]PartialDownload, error) {
+	// Partial path -> job
+	jobs := make(map[string]*BatchJobState)
+	if state, err := LoadBatchState(m.config.BatchStateFile()); err == nil {
+		for _, job := range state.Jobs {
+			if job.Status == JobCompleted {
+				continue
+			}
+			partial := m.config.PartialPath(downloadPath(job.Model, job.Result))
+			jobs[partial] = job
+		}
+	}
+
+	var partials []PartialDownload
+	seen := make(map[string]bool)
+	add := func(path string, info os.FileInfo, modelType ModelType) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+
+		partial := PartialDownload{Path: path, Type: modelType, Size: partialBytes(path)}
+		if job, ok := jobs[path]; ok {
+			partial.Target = downloadPath(job.Model, job.Result)
+			partial.Type = job.Model.Type
+			partial.Expected = job.Result.Size
+			partial.Resume = true
+		} else if m.config.TempDir == "" || filepath.Dir(path) != filepath.Clean(m.config.TempDir) {
+			partial.Target = trimTmp(path)
+		}
+		if partial.Target != "" {
+			if fileExists(partial.Target) && !partial.Resume {
+				return // a finished model with a leftover temp; not a partial download
+			}
+			if _, stale := inspectLock(partial.Target + ".lock"); fileExists(partial.Target+".lock") && !stale {
+				partial.Active = true
+			}
+		}
+		partials = append(partials, partial)
+	}
+
+	for _, modelType := range knownModelTypes {
+		if !m.config.TypeFilter.Allows(modelType) {
+			continue
+		}
+		for _, dir := range m.config.SearchDirs(modelType) {
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() || !isPartialName(info.Name()) {
+					return nil
+				}
+				add(path, info, modelType)
+				return nil
+			})
+		}
+	}
+
+	if m.config.TempDir != "" {
+		entries, err := os.ReadDir(m.config.TempDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read temp_dir: %w", err)
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || info.IsDir() || !isPartialName(entry.Name()) {
+				continue
+			}
+			add(filepath.Join(m.config.TempDir, entry.Name()), info, "")
+		}
+	}
+
+	sort.Slice(partials, func(i, j int) bool { return partials[i].Path < partials[j].Path })
+	return partials, nil
+}
+
+// isPartialName reports whether a file name is a download's .tmp file.
+// Hidden ".name.*.tmp" files are writeFileAtomic's and are skipped.
+func isPartialName(name string) bool {
+	return strings.HasSuffix(name, ".tmp") && !strings.HasPrefix(name, ".")
+}
+
+// trimTmp strips every trailing .tmp from a partial file's path
+func trimTmp(path string) string {
+	for strings.HasSuffix(path, ".tmp") {
+		path = strings.TrimSuffix(path, ".tmp")
+	}
+	return path
+}
+
+// printPartialDownloads lists partial downloads for --status
+func printPartialDownloads(partials []PartialDownload) {
+	fmt.Printf("\nIncomplete downloads: %d\n", len(partials))
+	for _, partial := range partials {
+		size := formatBytes(partial.Size)
+		if partial.Expected > 0 {
+			size = fmt.Sprintf("%s of %s", size, formatBytes(partial.Expected))
+		}
+
+		var note string
+		switch {
+		case partial.Active:
+			note = "downloading now"
+		case partial.Oversized():
+			note = "larger than the model, can't resume"
+		case partial.Resume:
+			note = "--resume-batch continues it"
+		case partial.Target == "":
+			note = "unknown model"
+		}
+		if note != "" {
+			note = ", " + note
+		}
+		fmt.Printf("  %s (%s%s)\n", partial.Path, size, note)
+	}
+	fmt.Println("Delete them with --clean-partial.")
+}
+
+// CleanPartialDownloads deletes partial downloads that no process is
+// working on, after confirming unless --yes was given
+func (m *ModelManager) CleanPartialDownloads() error {
+	partials, err := m.FindPartialDownloads()
+	if err != nil {
+		return err
+	}
+
+	var stale []PartialDownload
+	var total int64
+	for _, partial := range partials {
+		if partial.Active {
+			fmt.Printf("Skipping %s: being downloaded\n", partial.Path)
+			continue
+		}
+		stale = append(stale, partial)
+		total += partial.Size
+	}
+	if len(stale) == 0 {
+		fmt.Println("No incomplete downloads to delete.")
+		return nil
+	}
+
+	fmt.Printf("Incomplete downloads (%s):\n", formatBytes(total))
+	for _, partial := range stale {
+		fmt.Printf("  %10s  %s\n", formatBytes(partial.Size), partial.Path)
+	}
+
+	if !m.assumeYes {
+		if !isInteractive() {
+			return fmt.Errorf("not deleting without confirmation; rerun with --yes")
+		}
+		fmt.Print("Delete them? [y/N] ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+		if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+			fmt.Println("Nothing deleted.")
+			return nil
+		}
+	}
+
+	for _, partial := range stale {
+		if err := removePartial(partial.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", partial.Path, err)
+		}
+	}
+	fmt.Printf("Deleted %d incomplete downloads (%s)\n", len(stale), formatBytes(total))
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrPermissions is returned when a downloaded model couldn't be given its
+// configured mode or owner. The file is in place, so it is not retried.
+var ErrPermissions = errors.New("failed to set permissions")
+
+// modelPermissions are the mode and owner applied to downloaded models
+// and the directories created for them
+type modelPermissions struct {
+	fileMode os.FileMode // 0: leave as created, 0644 less the umask
+	dirMode  os.FileMode // 0: leave as created, 0755 less the umask
+	uid, gid int         // -1: unchanged
+}
+
+// Permissions parses FileMode, DirMode, Owner and Group
+func (c *Config) Permissions() (modelPermissions, error) {
+	perms := modelPermissions{uid: -1, gid: -1}
+
+	var err error
+	if perms.fileMode, err = parseMode(c.FileMode); err != nil {
+		return perms, fmt.Errorf("file_mode: %w", err)
+	}
+	if perms.dirMode, err = parseMode(c.DirMode); err != nil {
+		return perms, fmt.Errorf("dir_mode: %w", err)
+	}
+
+	if c.Owner != "" {
+		if perms.uid, err = lookupID(c.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		}); err != nil {
+			return perms, fmt.Errorf("owner: %w", err)
+		}
+	}
+	if c.Group != "" {
+		if perms.gid, err = lookupID(c.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return perms, fmt.Errorf("group: %w", err)
+		}
+	}
+
+	return perms, nil
+}
+
+// parseMode parses an octal mode such as "0664"
+func parseMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || n > 0777 {
+		return 0, fmt.Errorf("invalid mode %q, expected octal like \"0664\"", mode)
+	}
+	return os.FileMode(n), nil
+}
+
+// lookupID resolves a user or group name to its numeric ID; numbers are
+// taken as IDs
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
+
+// apply sets the configured mode and owner on path. Modes are set with
+// chmod, so they are exact regardless of the umask.
+func (p modelPermissions) apply(path string, mode os.FileMode) error {
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			return fmt.Errorf("%w: %v", ErrPermissions, err)
+		}
+	}
+	if p.uid != -1 || p.gid != -1 {
+		if err := os.Chown(path, p.uid, p.gid); err != nil {
+			return fmt.Errorf("%w: %v", ErrPermissions, err)
+		}
+	}
+	return nil
+}
+
+// applyFile sets the configured permissions on a downloaded model
+func (p modelPermissions) applyFile(path string) error {
+	return p.apply(path, p.fileMode)
+}
+
+// mkdirAll creates dir and any missing parents, giving the ones it
+// created the configured permissions
+func (p modelPermissions) mkdirAll(dir string) error {
+	var created []string
+	for d := dir; !dirExists(d); d = filepath.Dir(d) {
+		created = append(created, d)
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+
+	mode := p.dirMode
+	if mode == 0 {
+		mode = 0755
+	}
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+
+	// Parents first, so each is accessible before its children change
+	for i := len(created) - 1; i >= 0; i-- {
+		if err := p.apply(created[i], p.dirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrHookFailed is returned when the post-download hook exits non-zero.
+// The file is already in place, so the download is not retried.
+var ErrHookFailed = errors.New("post-download hook failed")
+
+// hookOutputLimit caps how much hook output is kept in an error
+const hookOutputLimit = 512
+
+// runPostDownloadHook runs the configured hook for a finished download.
+// Each argument is expanded separately and no shell is involved, so file
+// names with spaces or quotes are passed through intact.
+func (d *DownloadManager) runPostDownloadHook(ctx context.Context, job DownloadJob) error {
+	hook := d.config.PostDownloadHook
+	if len(hook) == 0 {
+		return nil
+	}
+
+	if d.config.HookTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.config.HookTimeout)
+		defer cancel()
+	}
+
+	expand := strings.NewReplacer(
+		"{path}", job.Model.LocalPath,
+		"{name}", job.Model.Name,
+		"{type}", string(job.Model.Type),
+		"{source}", job.SearchResult.Source,
+	).Replace
+
+	args := make([]string, len(hook))
+	for i, arg := range hook {
+		args[i] = expand(arg)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"MODEL_PATH="+job.Model.LocalPath,
+		"MODEL_NAME="+job.Model.Name,
+		"MODEL_TYPE="+string(job.Model.Type),
+		"MODEL_SOURCE="+job.SearchResult.Source,
+	)
+
+	output, err := cmd.CombinedOutput()
+	output = bytes.TrimSpace(output)
+	if err == nil {
+		if len(output) > 0 {
+			log.Printf("Hook for %s: %s\n", job.Model.Name, output)
+		}
+		return nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", d.config.HookTimeout)
+	}
+	if len(output) > hookOutputLimit {
+		output = output[len(output)-hookOutputLimit:]
+	}
+	if len(output) > 0 {
+		err = fmt.Errorf("%v: %s", err, output)
+	}
+	err = fmt.Errorf("%w for %s: %v", ErrHookFailed, job.Model.Name, err)
+
+	if d.config.IgnoreHookFailure {
+		log.Printf("Warning: %v\n", err)
+		return nil
+	}
+	return err
+}
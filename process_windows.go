@@ -0,0 +1,28 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259 // STILL_ACTIVE exit code
+	errorAccessDenied              = syscall.Errno(5)
+)
+
+// processRunning reports whether pid exists. Signals aren't supported on
+// Windows, so the process is opened and its exit code checked.
+func processRunning(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Another user's process can't be opened, but it exists
+		return err == errorAccessDenied
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
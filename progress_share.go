@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// progressInterval is how often a running batch saves its progress
+const progressInterval = time.Second
+
+// staleProgressAge is how long a progress file may go without an update
+// before --status assumes its process died
+const staleProgressAge = 10 * progressInterval
+
+// SharedProgress is a running batch's progress, saved for --status in
+// another process
+type SharedProgress struct {
+	PID        int                   `json:"pid"`
+	Host       string                `json:"host"`
+	StartedAt  time.Time             `json:"started_at"`
+	UpdatedAt  time.Time             `json:"updated_at"`
+	TotalBytes int64                 `json:"total_bytes"`
+	Downloaded int64                 `json:"downloaded"`
+	Speed      float64               `json:"speed_mbps"`
+	ETA        time.Duration         `json:"eta"`
+	Models     []SharedModelProgress `json:"models"`
+	Stale      bool                  `json:"stale"` // set when read, not saved
+}
+
+// SharedModelProgress is one download within SharedProgress
+type SharedModelProgress struct {
+	Name       string    `json:"name"`
+	Type       ModelType `json:"type"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total"`
+	Completed  bool      `json:"completed"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ProgressFile returns where a running batch shares its progress
+func (c *Config) ProgressFile() string {
+	return filepath.Join(filepath.Dir(c.ProvenanceFile()), ".model-manager-progress.json")
+}
+
+// shareProgress saves the batch progress every progressInterval until the
+// returned stop function is called, which removes the file
+func (d *DownloadManager) shareProgress() (stop func()) {
+	path := d.config.ProgressFile()
+	host, _ := os.Hostname()
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := d.saveProgress(path, host); err != nil {
+				log.Printf("Failed to save progress: %v\n", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+		os.Remove(path)
+	}
+}
+
+// saveProgress writes one progress snapshot
+func (d *DownloadManager) saveProgress(path, host string) error {
+	batch := d.GetBatchProgress()
+
+	d.mu.Lock()
+	started := d.batchStart
+	d.mu.Unlock()
+
+	shared := SharedProgress{
+		PID:        os.Getpid(),
+		Host:       host,
+		StartedAt:  started,
+		UpdatedAt:  time.Now(),
+		TotalBytes: batch.TotalBytes,
+		Downloaded: batch.Downloaded,
+		Speed:      batch.Speed,
+		ETA:        batch.ETA,
+	}
+	for name, progress := range batch.Models {
+		model := SharedModelProgress{
+			Name:       name,
+			Type:       progress.Model.Type,
+			Downloaded: progress.Downloaded,
+			Total:      progress.Total,
+			Completed:  progress.Completed,
+		}
+		if progress.Error != nil && !progress.Completed {
+			model.Error = progress.Error.Error()
+		}
+		shared.Models = append(shared.Models, model)
+	}
+	sort.Slice(shared.Models, func(i, j int) bool { return shared.Models[i].Name < shared.Models[j].Name })
+
+	data, err := json.MarshalIndent(shared, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// LoadSharedProgress reads another process's batch progress, returning
+// nil if no batch is running. A file that stopped updating, or whose
+// process is gone, is marked Stale.
+func LoadSharedProgress(path string) (*SharedProgress, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read progress: %w", err)
+	}
+
+	var shared SharedProgress
+	if err := json.Unmarshal(data, &shared); err != nil {
+		return nil, fmt.Errorf("failed to parse progress: %w", err)
+	}
+
+	shared.Stale = time.Since(shared.UpdatedAt) > staleProgressAge
+	// PIDs only mean something on the host that wrote the file
+	if host, _ := os.Hostname(); host == shared.Host && !processAlive(shared.PID) {
+		shared.Stale = true
+	}
+	return &shared, nil
+}
+
+// printSharedProgress prints a running batch for --status
+func printSharedProgress(shared *SharedProgress) {
+	if shared.Stale {
+		fmt.Printf("\nA download batch (pid %d) stopped updating %s ago; it is no longer running.\n",
+			shared.PID, time.Since(shared.UpdatedAt).Round(time.Second))
+		return
+	}
+
+	fmt.Printf("\nDownloading (pid %d, started %s): %s / %s at %.2f MB/s, %s remaining\n",
+		shared.PID, shared.StartedAt.Format(time.Kitchen),
+		formatBytes(shared.Downloaded), formatBytes(shared.TotalBytes), shared.Speed, formatETA(shared.ETA))
+	for _, model := range shared.Models {
+		switch {
+		case model.Completed:
+			fmt.Printf("  done:        %s\n", model.Name)
+		case model.Error != "":
+			fmt.Printf("  error:       %s (%s)\n", model.Name, model.Error)
+		case model.Total > 0:
+			fmt.Printf("  %5.1f%%       %s (%s / %s)\n", float64(model.Downloaded)/float64(model.Total)*100,
+				model.Name, formatBytes(model.Downloaded), formatBytes(model.Total))
+		default:
+			fmt.Printf("  starting:    %s\n", model.Name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ProvenanceRecord records where a downloaded model came from
+type ProvenanceRecord struct {
+	Name         string    `json:"name"`
+	Type         ModelType `json:"type"`
+	LocalPath    string    `json:"local_path"`
+	Source       string    `json:"source"`
+	DownloadURL  string    `json:"download_url"`
+	Hash         string    `json:"hash,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	ModelID      int       `json:"civitai_model_id,omitempty"`
+	VersionID    int       `json:"civitai_version_id,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// Provenance is the on-disk record of every model this tool downloaded
+type Provenance struct {
+	path    string
+	mu      sync.Mutex
+	Records map[string]ProvenanceRecord `json:"records"` // keyed by Model.Key()
+}
+
+// LoadProvenance loads the provenance file, returning an empty record set
+// if it doesn't exist yet
+func LoadProvenance(path string) (*Provenance, error) {
+	p := &Provenance{path: path, Records: make(map[string]ProvenanceRecord)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return p, fmt.Errorf("failed to read provenance: %w", err)
+	}
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return p, fmt.Errorf("failed to parse provenance: %w", err)
+	}
+	if p.Records == nil {
+		p.Records = make(map[string]ProvenanceRecord)
+	}
+
+	return p, nil
+}
+
+// Record stores where a model came from and saves the file
+func (p *Provenance) Record(model Model, result SearchResult) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Records[model.Key()] = ProvenanceRecord{
+		Name:         model.Name,
+		Type:         model.Type,
+		LocalPath:    model.LocalPath,
+		Source:       result.Source,
+		DownloadURL:  result.DownloadURL,
+		Hash:         result.Hash,
+		Size:         result.Size,
+		ModelID:      result.ModelID,
+		VersionID:    result.VersionID,
+		DownloadedAt: time.Now(),
+	}
+
+	return p.save()
+}
+
+// Relocate updates the record of a model file moved to another type's
+// directory, so updates keep finding it
+func (p *Provenance) Relocate(oldPath, newPath string, modelType ModelType) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, record := range p.Records {
+		if record.LocalPath != oldPath {
+			continue
+		}
+		delete(p.Records, key)
+		record.LocalPath = newPath
+		record.Type = modelType
+		p.Records[Model{Name: record.Name, Type: modelType}.Key()] = record
+		return p.save()
+	}
+	return nil
+}
+
+// List returns a snapshot of all records
+func (p *Provenance) List() []ProvenanceRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	records := make([]ProvenanceRecord, 0, len(p.Records))
+	for _, record := range p.Records {
+		records = append(records, record)
+	}
+	return records
+}
+
+// save writes the records to disk; the caller must hold p.mu
+func (p *Provenance) save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(p.path, data, 0644)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Misfiled is an installed model whose file says it belongs to another
+// type's directory
+type Misfiled struct {
+	Model    Model
+	Detected ModelType
+	By       string // what the type was read from
+	Target   string // where the file belongs
+}
+
+// FindMisfiled checks every model in the type directories against the
+// type its safetensors metadata declares, falling back to a CivitAI hash
+// lookup when the metadata gives no clear signal and a token is set
+func (m *ModelManager) FindMisfiled() ([]Misfiled, error) {
+	var misfiled []Misfiled
+	seen := make(map[string]bool) // directories may be shared between types
+
+	for _, modelType := range knownModelTypes {
+		if !m.config.TypeFilter.Allows(modelType) {
+			continue
+		}
+
+		dir := m.config.GetModelDir(modelType)
+		models, err := m.scanner.scanDir(dir, modelType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", modelType, err)
+		}
+
+		for _, model := range models {
+			if seen[model.LocalPath] {
+				continue
+			}
+			seen[model.LocalPath] = true
+
+			detected, by := m.detectModelType(model)
+			if detected == "" || sameModelType(detected, modelType) {
+				continue
+			}
+			targetDir := m.config.GetModelDir(detected)
+			if targetDir == dir {
+				continue
+			}
+			misfiled = append(misfiled, Misfiled{
+				Model:    model,
+				Detected: detected,
+				By:       by,
+				Target:   filepath.Join(targetDir, filepath.FromSlash(model.Name)),
+			})
+		}
+	}
+
+	return misfiled, nil
+}
+
+// detectModelType returns the type a model file really is and what said
+// so, or "" when nothing does
+func (m *ModelManager) detectModelType(model Model) (ModelType, string) {
+	if strings.EqualFold(filepath.Ext(model.LocalPath), ".safetensors") {
+		if header, err := ReadSafetensorsHeader(model.LocalPath); err == nil {
+			if detected := header.DetectType(); detected != "" {
+				return detected, "safetensors metadata"
+			}
+		}
+	}
+
+	if m.config.CivitAIToken == "" {
+		return "", ""
+	}
+	// CivitAI looks files up by BLAKE3 too, which hashes much faster
+	hash, err := blake3File(model.LocalPath)
+	if err != nil {
+		fmt.Printf("  %s: failed to hash: %v\n", model.Name, err)
+		return "", ""
+	}
+	result, err := m.downloader.civitClient.GetModelByHash(hash)
+	if err != nil {
+		fmt.Printf("  %s: CivitAI lookup failed: %v\n", model.Name, err)
+		return "", ""
+	}
+	if result == nil {
+		return "", ""
+	}
+	return result.DeclaredType, "CivitAI"
+}
+
+// RepairModels reports models sitting in the wrong type's directory and,
+// when apply is set, moves them into the right one
+func (m *ModelManager) RepairModels(apply bool) error {
+	fmt.Println("Checking installed models for misfiled types...")
+	if m.config.CivitAIToken == "" {
+		fmt.Println("No civitai_token configured; only safetensors metadata is checked.")
+	}
+
+	misfiled, err := m.FindMisfiled()
+	if err != nil {
+		return err
+	}
+
+	if len(misfiled) == 0 {
+		fmt.Println("All models are in the right directory.")
+		return nil
+	}
+
+	fmt.Printf("\n%d misfiled models:\n", len(misfiled))
+	for _, entry := range misfiled {
+		fmt.Printf("  - %s: in %s but %s says %s -> %s\n",
+			entry.Model.Name, entry.Model.Type, entry.By, entry.Detected, entry.Target)
+	}
+
+	if !apply {
+		fmt.Println("\nDry run; pass --apply to move these models.")
+		return nil
+	}
+
+	fmt.Println()
+	moved := 0
+	for _, entry := range misfiled {
+		if fileExists(entry.Target) {
+			fmt.Printf("  skipped %s: %s already exists\n", entry.Model.Name, entry.Target)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", entry.Model.Name, err)
+		}
+		if err := moveFile(entry.Model.LocalPath, entry.Target); err != nil {
+			return fmt.Errorf("failed to move %s: %w", entry.Model.Name, err)
+		}
+		if err := m.downloader.Provenance().Relocate(entry.Model.LocalPath, entry.Target, entry.Detected); err != nil {
+			fmt.Printf("  %s: failed to update provenance: %v\n", entry.Model.Name, err)
+		}
+		fmt.Printf("  moved %s to %s\n", entry.Model.Name, entry.Target)
+		moved++
+	}
+	fmt.Printf("Moved %d of %d models\n", moved, len(misfiled))
+
+	return nil
+}
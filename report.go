@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunReport collects what a workflow run found, searched and downloaded
+type RunReport struct {
+	Workflow string
+	Present  []Model
+	Missing  []Model
+	Results  map[string]SearchResult
+	Searches map[string]SearchReport
+}
+
+// ReportRow is one model's line in a written report
+type ReportRow struct {
+	Name   string
+	Type   ModelType
+	Status string // present, downloaded, failed, not found or found
+	Size   string
+	Source string
+	URL    string
+	Detail string
+}
+
+// rows flattens the run into one row per model, with download outcomes
+// taken from progress
+func (r *RunReport) rows(progress map[string]*DownloadProgress) []ReportRow {
+	var rows []ReportRow
+
+	for _, model := range r.Present {
+		rows = append(rows, ReportRow{
+			Name:   model.Name,
+			Type:   model.Type,
+			Status: "present",
+			Size:   reportSize(model.Size),
+			Detail: model.LocalPath,
+		})
+	}
+
+	for _, model := range r.Missing {
+		row := ReportRow{Name: model.Name, Type: model.Type, Status: "not found"}
+
+		result, found := r.Results[model.Name]
+		if !found {
+			var outcomes []string
+			for _, attempt := range r.Searches[model.Name].Attempts {
+				outcomes = append(outcomes, attempt.Source+": "+attempt.Outcome)
+			}
+			row.Detail = strings.Join(outcomes, "; ")
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Status = "found"
+		row.Size = reportSize(result.Size)
+		row.Source = result.Source
+		row.URL = result.DownloadURL
+		if p, ok := progress[model.Name]; ok {
+			switch {
+			case p.Skipped:
+				row.Status = "present"
+			case p.Completed:
+				row.Status = "downloaded"
+			case p.Error != nil:
+				row.Status = "failed"
+				row.Detail = p.Error.Error()
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Type != rows[j].Type {
+			return rows[i].Type < rows[j].Type
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+// reportSize formats a size, leaving unknown sizes blank
+func reportSize(size int64) string {
+	if size <= 0 {
+		return ""
+	}
+	return formatBytes(size)
+}
+
+// Markdown renders the report as a Markdown document
+func (r *RunReport) Markdown(progress map[string]*DownloadProgress) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Model report: %s\n\n", r.Workflow)
+	fmt.Fprintf(&b, "Generated %s. %d present, %d missing.\n\n",
+		time.Now().Format(time.RFC1123), len(r.Present), len(r.Missing))
+
+	b.WriteString("| Model | Type | Status | Size | Source | Details |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, row := range r.rows(progress) {
+		name := markdownEscape(row.Name)
+		if row.URL != "" {
+			name = fmt.Sprintf("[%s](%s)", name, row.URL)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			name, row.Type, row.Status, row.Size, row.Source, markdownEscape(row.Detail))
+	}
+
+	return b.Bytes()
+}
+
+// markdownEscape keeps a value from breaking a table cell
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Model report: {{.Workflow}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Model report: {{.Workflow}}</h1>
+<p>Generated {{.Generated}}. {{.Present}} present, {{.Missing}} missing.</p>
+<table>
+<tr><th>Model</th><th>Type</th><th>Status</th><th>Size</th><th>Source</th><th>Details</th></tr>
+{{range .Rows}}<tr><td>{{if .URL}}<a href="{{.URL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td><td>{{.Type}}</td><td>{{.Status}}</td><td>{{.Size}}</td><td>{{.Source}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// HTML renders the report as a standalone HTML page
+func (r *RunReport) HTML(progress map[string]*DownloadProgress) ([]byte, error) {
+	var b bytes.Buffer
+	err := reportTemplate.Execute(&b, map[string]interface{}{
+		"Workflow":  r.Workflow,
+		"Generated": time.Now().Format(time.RFC1123),
+		"Present":   len(r.Present),
+		"Missing":   len(r.Missing),
+		"Rows":      r.rows(progress),
+	})
+	return b.Bytes(), err
+}
+
+// WriteReport writes the last run's report in the given format ("md" or
+// "html"); an empty format is inferred from the file extension
+func (m *ModelManager) WriteReport(path, format string) error {
+	if m.lastRun == nil {
+		return fmt.Errorf("no workflow has been processed")
+	}
+
+	if format == "" {
+		format = "md"
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".html" || ext == ".htm" {
+			format = "html"
+		}
+	}
+
+	var data []byte
+	var err error
+	progress := m.downloader.GetProgress()
+	switch format {
+	case "md", "markdown":
+		data = m.lastRun.Markdown(progress)
+	case "html":
+		data, err = m.lastRun.HTML(progress)
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0644)
+}
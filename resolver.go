@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Resolver finds a download source for a model. Search returns nil and no
+// error when the resolver has nothing for the model; errors explain why it
+// couldn't look or what it rejected, and end up in the SearchReport.
+// Results are fetched by their Source, so it must be one the download
+// manager knows how to download from.
+type Resolver interface {
+	Name() string
+	Search(model Model) (*SearchResult, error)
+}
+
+// CandidateResolver is a Resolver that can return every match it found,
+// best first, for callers choosing among alternatives
+type CandidateResolver interface {
+	Resolver
+	SearchAll(model Model) ([]SearchResult, error)
+}
+
+// RegisterResolver adds a model source. Resolvers not named in the
+// configured source_priority are tried before it, in registration order;
+// naming one there places it among the built-in sources instead.
+func (m *ModelManager) RegisterResolver(r Resolver) {
+	if _, exists := m.resolvers[r.Name()]; !exists && !isBuiltinResolver(r.Name()) {
+		m.customResolvers = append(m.customResolvers, r.Name())
+	}
+	m.resolvers[r.Name()] = r
+}
+
+// isBuiltinResolver reports whether a name is one of the sources this
+// tool ships and orders itself
+func isBuiltinResolver(name string) bool {
+	switch name {
+	case "model urls", "civitai air", "model list", "huggingface", "civitai", "civitai hash":
+		return true
+	}
+	return false
+}
+
+// registerBuiltinResolvers installs the shipped model sources
+func (m *ModelManager) registerBuiltinResolvers() {
+	m.resolvers = make(map[string]Resolver)
+	if len(m.config.ModelURLs) > 0 {
+		m.RegisterResolver(&modelURLResolver{urls: m.config.ModelURLs, civitai: m.downloader.civitClient})
+	}
+	if m.modelList != nil {
+		m.RegisterResolver(&modelListResolver{list: m.modelList})
+	}
+	m.RegisterResolver(&huggingFaceResolver{client: m.downloader.hfClient, config: m.config})
+	m.RegisterResolver(&civitAIAIRResolver{client: m.downloader.civitClient})
+	m.RegisterResolver(&civitAIResolver{client: m.downloader.civitClient})
+	m.RegisterResolver(&civitAIHashResolver{client: m.downloader.civitClient, config: m.config})
+}
+
+// resolversFor returns the resolvers to try for a model type, in order:
+// configured model URLs, the workflow's AIR, custom resolvers, the model
+// list, the configured source priority, and finally the CivitAI hash lookup
+func (m *ModelManager) resolversFor(modelType ModelType) []Resolver {
+	var ordered []Resolver
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if r, ok := m.resolvers[name]; ok && !seen[name] {
+			ordered = append(ordered, r)
+			seen[name] = true
+		}
+	}
+
+	sources := m.config.SourcesFor(modelType)
+	configured := make(map[string]bool)
+	for _, name := range sources {
+		configured[name] = true
+	}
+
+	add("model urls")
+	add("civitai air")
+	for _, name := range m.customResolvers {
+		if !configured[name] {
+			add(name)
+		}
+	}
+	add("model list")
+	for _, name := range sources {
+		if _, ok := m.resolvers[name]; !ok {
+			log.Printf("Unknown source in source_priority: %s\n", name)
+			continue
+		}
+		add(name)
+	}
+	add("civitai hash")
+
+	return ordered
+}
+
+// modelURLResolver uses download URLs pinned in the config. CivitAI
+// model pages are resolved to their files through the API.
+type modelURLResolver struct {
+	urls    map[string]string
+	civitai *CivitAIClient
+}
+
+func (r *modelURLResolver) Name() string { return "model urls" }
+
+func (r *modelURLResolver) Search(model Model) (*SearchResult, error) {
+	filename := path.Base(normalizeModelName(model.Name))
+	rawURL, ok := r.urls[model.Name]
+	if !ok {
+		if rawURL, ok = r.urls[filename]; !ok {
+			return nil, nil
+		}
+	}
+
+	source := sourceForURL(rawURL)
+	if source == "" {
+		return nil, fmt.Errorf("unsupported URL %s", rawURL)
+	}
+	if source == "civitai" {
+		if u, err := url.Parse(rawURL); err == nil && !strings.HasPrefix(u.Path, "/api/download/") {
+			return r.resolveCivitAIPage(u, model.Type)
+		}
+	}
+	return &SearchResult{
+		Name:        filename,
+		Source:      source,
+		DownloadURL: rawURL,
+		ModelType:   model.Type,
+	}, nil
+}
+
+// resolveCivitAIPage resolves a CivitAI model page or version URL to the
+// primary file of its version, or of the model's newest version
+func (r *modelURLResolver) resolveCivitAIPage(u *url.URL, modelType ModelType) (*SearchResult, error) {
+	spec, err := parseCivitAIURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	versionID := spec.VersionID
+	if versionID == 0 {
+		if versionID, err = r.civitai.LatestVersionID(spec.ModelID); err != nil {
+			return nil, err
+		}
+	}
+	return r.civitai.GetModelByVersionID(versionID, modelType)
+}
+
+// modelListResolver looks models up in a curated ComfyUI-Manager list
+type modelListResolver struct {
+	list *ModelList
+}
+
+func (r *modelListResolver) Name() string { return "model list" }
+
+func (r *modelListResolver) Search(model Model) (*SearchResult, error) {
+	if result := r.list.Lookup(model); result != nil {
+		return result, nil
+	}
+	return nil, fmt.Errorf("not listed")
+}
+
+// huggingFaceResolver searches HuggingFace by cleaned file name
+type huggingFaceResolver struct {
+	client *HuggingFaceClient
+	config *Config
+}
+
+func (r *huggingFaceResolver) Name() string { return "huggingface" }
+
+func (r *huggingFaceResolver) Search(model Model) (*SearchResult, error) {
+	return firstResult(r.SearchAll(model))
+}
+
+func (r *huggingFaceResolver) SearchAll(model Model) ([]SearchResult, error) {
+	if r.config.HuggingFaceToken == "" {
+		return nil, fmt.Errorf("skipped, no huggingface_token configured")
+	}
+	results, err := r.client.SearchModels(cleanModelName(model.Name), model.Type)
+	return preferQuant(model.Name, matchRequestedFile(model.Name, results)), err
+}
+
+// civitAIResolver searches CivitAI by cleaned file name
+type civitAIResolver struct {
+	client *CivitAIClient
+}
+
+func (r *civitAIResolver) Name() string { return "civitai" }
+
+func (r *civitAIResolver) Search(model Model) (*SearchResult, error) {
+	return firstResult(r.SearchAll(model))
+}
+
+func (r *civitAIResolver) SearchAll(model Model) ([]SearchResult, error) {
+	results, err := r.client.SearchModels(cleanModelName(model.Name), model.Type)
+	return preferQuant(model.Name, results), err
+}
+
+// civitAIHashResolver looks a model up on CivitAI by the hash recorded in
+// the workflow
+type civitAIHashResolver struct {
+	client *CivitAIClient
+	config *Config
+}
+
+func (r *civitAIHashResolver) Name() string { return "civitai hash" }
+
+func (r *civitAIHashResolver) Search(model Model) (*SearchResult, error) {
+	switch {
+	case model.Hash == "":
+		return nil, fmt.Errorf("skipped, workflow has no hash")
+	case r.config.CivitAIToken == "":
+		return nil, fmt.Errorf("skipped, no civitai_token configured")
+	}
+
+	result, err := r.client.GetModelByHash(model.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("no match for %s", model.Hash)
+	}
+	result.ModelType = model.Type
+	return result, nil
+}
+
+// firstResult picks the best search hit, reporting an empty search as a miss
+func firstResult(results []SearchResult, err error) (*SearchResult, error) {
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return &results[0], nil
+}
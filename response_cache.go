@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// responseCache stores API responses on disk keyed by URL and revalidates
+// them with If-None-Match, so repeated searches cost a 304 at most
+type responseCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// cachedResponse is one cache entry on disk
+type cachedResponse struct {
+	URL       string    `json:"url"`
+	ETag      string    `json:"etag"`
+	Body      []byte    `json:"body"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// newResponseCache creates a cache, or returns nil if caching is disabled
+func newResponseCache(dir string, ttl time.Duration) *responseCache {
+	if dir == "" || ttl <= 0 {
+		return nil
+	}
+	return &responseCache{dir: dir, ttl: ttl}
+}
+
+// Do performs req, serving the cached body when the server answers 304.
+// A nil cache just performs the request.
+func (c *responseCache) Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if c == nil || req.Method != http.MethodGet {
+		return client.Do(req)
+	}
+
+	key := req.URL.String()
+	entry := c.load(key)
+	if entry != nil {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Body = io.NopCloser(bytes.NewReader(entry.Body))
+		return resp, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(&cachedResponse{URL: key, ETag: etag, Body: body, FetchedAt: time.Now()})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// path returns the cache file for a URL
+func (c *responseCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns a fresh cache entry for a URL, or nil
+func (c *responseCache) load(key string) *cachedResponse {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != key {
+		return nil
+	}
+
+	if time.Since(entry.FetchedAt) > c.ttl {
+		os.Remove(c.path(key))
+		return nil
+	}
+
+	return &entry
+}
+
+// store saves a cache entry; failures only cost a future cache miss
+func (c *responseCache) store(entry *cachedResponse) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	writeFileAtomic(c.path(entry.URL), data, 0644)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxSafetensorsHeader bounds the header size so a corrupt length prefix
+// can't make us allocate gigabytes
+const maxSafetensorsHeader = 100 * 1024 * 1024
+
+// SafetensorsHeader is the parsed JSON header of a .safetensors file
+type SafetensorsHeader struct {
+	Metadata map[string]string
+	Tensors  []string
+}
+
+// ReadSafetensorsMetadata reads the __metadata__ map from a .safetensors
+// file without loading the tensors
+func ReadSafetensorsMetadata(path string) (map[string]string, error) {
+	header, err := ReadSafetensorsHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	return header.Metadata, nil
+}
+
+// ReadSafetensorsHeader reads the length-prefixed JSON header of a
+// .safetensors file: its metadata and tensor names
+func ReadSafetensorsHeader(path string) (*SafetensorsHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var length uint64
+	if err := binary.Read(file, binary.LittleEndian, &length); err != nil {
+		return nil, fmt.Errorf("failed to read safetensors header length: %w", err)
+	}
+	if length == 0 || length > maxSafetensorsHeader {
+		return nil, fmt.Errorf("invalid safetensors header length: %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, fmt.Errorf("failed to read safetensors header: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse safetensors header: %w", err)
+	}
+
+	header := &SafetensorsHeader{Metadata: map[string]string{}}
+	for key, value := range raw {
+		if key == "__metadata__" {
+			if err := json.Unmarshal(value, &header.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to parse safetensors metadata: %w", err)
+			}
+			continue
+		}
+		header.Tensors = append(header.Tensors, key)
+	}
+
+	return header, nil
+}
+
+// BaseModel returns the base model the file was trained for, if declared
+func (h *SafetensorsHeader) BaseModel() string {
+	for _, key := range []string{"ss_base_model_version", "modelspec.base_model", "ss_sd_model_name"} {
+		if value := h.Metadata[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Architecture returns the declared architecture, e.g.
+// "stable-diffusion-xl-v1-base/lora"
+func (h *SafetensorsHeader) Architecture() string {
+	return h.Metadata["modelspec.architecture"]
+}
+
+// DetectType infers the model type from the metadata and tensor names,
+// returning "" when the header gives no clear signal
+func (h *SafetensorsHeader) DetectType() ModelType {
+	arch := strings.ToLower(h.Architecture())
+	switch {
+	case strings.HasSuffix(arch, "/lora"):
+		return ModelTypeLora
+	case strings.HasSuffix(arch, "/textual-inversion"):
+		return ModelTypeEmbedding
+	case strings.HasSuffix(arch, "/vae"):
+		return ModelTypeVAE
+	case strings.HasSuffix(arch, "/controlnet"):
+		return ModelTypeControlNet
+	}
+
+	if h.Metadata["ss_network_module"] != "" {
+		return ModelTypeLora
+	}
+
+	var hasDiffusion, hasVAE bool
+	for _, name := range h.Tensors {
+		switch {
+		case strings.Contains(name, "lora_up") || strings.Contains(name, "lora_down") ||
+			strings.Contains(name, "lora_A") || strings.Contains(name, "lora_B"):
+			return ModelTypeLora
+		case name == "emb_params" || strings.HasPrefix(name, "string_to_param"):
+			return ModelTypeEmbedding
+		case strings.HasPrefix(name, "control_model."):
+			return ModelTypeControlNet
+		case strings.HasPrefix(name, "model.diffusion_model."):
+			hasDiffusion = true
+		case strings.HasPrefix(name, "encoder.") || strings.HasPrefix(name, "decoder."):
+			hasVAE = true
+		}
+	}
+
+	switch {
+	case hasDiffusion:
+		return ModelTypeCheckpoint
+	case hasVAE:
+		return ModelTypeVAE
+	default:
+		return ""
+	}
+}
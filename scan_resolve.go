@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// resolveGroups orders the --scan --resolve groups; resolvers not listed
+// here (model urls, model list, custom ones) follow in name order
+var resolveGroups = []struct {
+	resolver string
+	label    string
+}{
+	{"huggingface", "Available on HuggingFace"},
+	{"civitai", "Available on CivitAI"},
+	{"civitai hash", "Found by hash on CivitAI"},
+}
+
+// ResolveWorkflow scans a workflow and searches for its missing models
+// without downloading, printing them grouped by where they were found so
+// a run can be planned before committing to it
+func (m *ModelManager) ResolveWorkflow(workflowPath string) error {
+	models, err := m.parser.ParseWorkflow(workflowPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse workflow: %w", err)
+	}
+
+	present, missing, err := m.scanner.ScanModels(models)
+	if err != nil {
+		return fmt.Errorf("failed to scan models: %w", err)
+	}
+
+	fmt.Printf("Present models: %d\n", len(present))
+	if len(missing) == 0 {
+		fmt.Println("All models are present!")
+		return nil
+	}
+
+	fmt.Printf("Searching for %d missing models...\n", len(missing))
+	results, reports := m.searchModels(missing)
+
+	groups := make(map[string][]Model)
+	var notFound []Model
+	for _, model := range missing {
+		if report := reports[model.Name]; report.FoundBy != "" {
+			groups[report.FoundBy] = append(groups[report.FoundBy], model)
+		} else {
+			notFound = append(notFound, model)
+		}
+	}
+
+	var total int64
+	printGroup := func(label string, models []Model) {
+		var size int64
+		for _, model := range models {
+			size += results[model.Name].Size
+		}
+		total += size
+		fmt.Printf("\n%s: %d models, %s\n", label, len(models), formatBytes(size))
+		for _, model := range models {
+			result := results[model.Name]
+			size := "size?"
+			if result.Size > 0 {
+				size = formatBytes(result.Size)
+			}
+			fmt.Printf("  %10s  %s (%s) -> %s\n", size, model.Name, model.Type, result.Name)
+		}
+	}
+
+	for _, group := range resolveGroups {
+		if models := groups[group.resolver]; len(models) > 0 {
+			printGroup(group.label, models)
+			delete(groups, group.resolver)
+		}
+	}
+	others := make([]string, 0, len(groups))
+	for resolver := range groups {
+		others = append(others, resolver)
+	}
+	sort.Strings(others)
+	for _, resolver := range others {
+		printGroup("Available from "+resolver, groups[resolver])
+	}
+
+	if len(notFound) > 0 {
+		fmt.Printf("\nNot found anywhere: %d models\n", len(notFound))
+		for _, model := range notFound {
+			fmt.Printf("  - %s (%s)\n", model.Name, model.Type)
+			for _, attempt := range reports[model.Name].Attempts {
+				fmt.Printf("      %s: %s\n", attempt.Source, attempt.Outcome)
+			}
+		}
+	}
+
+	fmt.Printf("\n%d of %d missing models can be downloaded (%s)\n", len(results), len(missing), formatBytes(total))
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// hfEndpointEnv is the huggingface_hub variable naming a HuggingFace mirror
+const hfEndpointEnv = "HF_ENDPOINT"
+
+// hfBaseURL returns where HuggingFace is reached: huggingface_url, then
+// $HF_ENDPOINT, then huggingface.co
+func (c *Config) hfBaseURL() string {
+	if c.HuggingFaceURL != "" {
+		return c.HuggingFaceURL
+	}
+	if endpoint := os.Getenv(hfEndpointEnv); endpoint != "" {
+		return endpoint
+	}
+	return defaultHFBaseURL
+}
+
+// civitAIBaseURL returns where CivitAI is reached: civitai_url or civitai.com
+func (c *Config) civitAIBaseURL() string {
+	if c.CivitAIURL != "" {
+		return c.CivitAIURL
+	}
+	return defaultCivitAIBaseURL
+}
+
+// checkBaseURL rejects a source base URL that isn't an absolute http(s)
+// URL; empty means the default
+func checkBaseURL(base string) error {
+	if base == "" {
+		return nil
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", base)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%q must not have a query or fragment", base)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// useSourceNames makes each job save its file under the source's file
+// name, beside where the workflow expects it, and link the workflow's
+// path to it. A model whose source file is already queued under that name
+// shares the download; a different file with the same name, or an
+// existing file that can't be verified as this one, keeps the workflow's
+// name so nothing is overwritten.
+func (d *DownloadManager) useSourceNames(jobs []DownloadJob) []DownloadJob {
+	var out []DownloadJob
+	queued := make(map[string]int) // source-named path -> index in out
+
+	for _, job := range jobs {
+		target := sourceNamedPath(job)
+		if target == "" {
+			out = append(out, job)
+			continue
+		}
+
+		if i, ok := queued[target]; ok {
+			if out[i].SearchResult.DownloadURL == job.SearchResult.DownloadURL {
+				fmt.Printf("%s is the same file as %s; linking it to %s\n",
+					job.Model.Name, out[i].Model.Name, target)
+				out[i].Links = append(out[i].Links, job.Model.LocalPath)
+				continue
+			}
+			log.Printf("%s and %s are different files named %s; keeping the workflow's name\n",
+				job.Model.Name, out[i].Model.Name, filepath.Base(target))
+			out = append(out, job)
+			continue
+		}
+
+		renamed := job
+		renamed.Model.LocalPath = target
+		if fileExists(target) && !d.alreadyVerified(renamed) {
+			log.Printf("%s already exists and may be another file; keeping the workflow's name for %s\n",
+				target, job.Model.Name)
+			out = append(out, job)
+			continue
+		}
+
+		renamed.Links = []string{job.Model.LocalPath}
+		queued[target] = len(out)
+		out = append(out, renamed)
+	}
+	return out
+}
+
+// sourceNamedPath returns where a job's file goes under the source's file
+// name, or "" when that is the workflow's name already or isn't usable
+func sourceNamedPath(job DownloadJob) string {
+	name := path.Base(job.SearchResult.Name)
+	if !hasModelExtension(name) || name != filepath.Base(name) {
+		return ""
+	}
+	target := filepath.Join(filepath.Dir(job.Model.LocalPath), name)
+	if target == job.Model.LocalPath {
+		return ""
+	}
+	return target
+}
+
+// linkWorkflowNames points the workflow paths of a source-named download
+// at its file with relative symlinks, falling back to hard links where
+// symlinks aren't allowed (e.g. Windows without developer mode)
+func linkWorkflowNames(job DownloadJob) {
+	for _, link := range job.Links {
+		target, err := filepath.Rel(filepath.Dir(link), job.Model.LocalPath)
+		if err != nil {
+			target = job.Model.LocalPath
+		}
+
+		// --force re-downloads replace the previous file or link
+		if _, err := os.Lstat(link); err == nil {
+			if err := os.Remove(link); err != nil {
+				log.Printf("Failed to replace %s with a link: %v\n", link, err)
+				continue
+			}
+		}
+		if err := os.Symlink(target, link); err != nil {
+			if err := os.Link(job.Model.LocalPath, link); err != nil {
+				log.Printf("Failed to link %s to %s: %v\n", link, job.Model.LocalPath, err)
+				continue
+			}
+		}
+		fmt.Printf("Linked %s to %s\n", link, filepath.Base(job.Model.LocalPath))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrStalled is returned when a download receives no data for too long
+var ErrStalled = errors.New("download stalled")
+
+// stallGuard cancels a request when no bytes arrive within the idle timeout.
+// The timer starts immediately, so a server that accepts the connection but
+// never sends headers is caught as well.
+type stallGuard struct {
+	cancel  context.CancelCauseFunc
+	ctx     context.Context
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// newStallGuard derives a context from parent that is cancelled with
+// ErrStalled after timeout of inactivity. A zero timeout disables the guard.
+func newStallGuard(parent context.Context, timeout time.Duration) *stallGuard {
+	ctx, cancel := context.WithCancelCause(parent)
+	g := &stallGuard{cancel: cancel, ctx: ctx, timeout: timeout}
+	if timeout > 0 {
+		g.timer = time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("%w: no data for %s", ErrStalled, timeout))
+		})
+	}
+	return g
+}
+
+// Context returns the context requests should use
+func (g *stallGuard) Context() context.Context {
+	return g.ctx
+}
+
+// Wrap returns a reader that resets the idle timer whenever data arrives
+func (g *stallGuard) Wrap(r io.Reader) io.Reader {
+	if g.timer == nil {
+		return r
+	}
+	return &stallReader{r: r, guard: g}
+}
+
+// Err reports a stall as ErrStalled instead of a bare context cancellation,
+// so it is retried rather than treated as a user interrupt
+func (g *stallGuard) Err(err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(g.ctx); errors.Is(cause, ErrStalled) {
+		return cause
+	}
+	return err
+}
+
+// Stop releases the guard's timer and context
+func (g *stallGuard) Stop() {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.cancel(nil)
+}
+
+// stallReader resets its guard's timer on every successful read
+type stallReader struct {
+	r     io.Reader
+	guard *stallGuard
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.guard.timer.Reset(s.guard.timeout)
+	}
+	return n, err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// TypeUsage is the storage used by one model type
+type TypeUsage struct {
+	Type  ModelType `json:"type"`
+	Dirs  []string  `json:"dirs"`
+	Count int       `json:"count"`
+	Bytes int64     `json:"bytes"`
+}
+
+// FilesystemUsage is the free space on a filesystem holding model dirs
+type FilesystemUsage struct {
+	Path       string `json:"path"` // first model directory found on it
+	FreeBytes  uint64 `json:"free_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+// StorageStatus summarizes model storage across every type
+type StorageStatus struct {
+	Types       []TypeUsage       `json:"types"`
+	TotalCount  int               `json:"total_count"`
+	TotalBytes  int64             `json:"total_bytes"`
+	Filesystems []FilesystemUsage `json:"filesystems"`
+	Downloads   *SharedProgress   `json:"downloads,omitempty"` // batch running in another process
+	Partials    []PartialDownload `json:"partial_downloads,omitempty"`
+}
+
+// StorageStatus scans every model directory for per-type totals and the
+// free space left on each filesystem
+func (m *ModelManager) StorageStatus() (*StorageStatus, error) {
+	status := &StorageStatus{}
+	seen := make(map[string]bool)
+	counted := make(map[string]bool) // text_encoders also lists the clip dir
+
+	for _, modelType := range knownModelTypes {
+		if !m.config.TypeFilter.Allows(modelType) {
+			continue
+		}
+
+		models, err := m.scanner.ScanDirectory(modelType)
+		if err != nil {
+			log.Printf("Error scanning %s: %v\n", modelType, err)
+			continue
+		}
+
+		usage := TypeUsage{Type: modelType, Dirs: m.config.SearchDirs(modelType), Count: len(models)}
+		for _, model := range models {
+			usage.Bytes += model.Size
+			if !counted[model.LocalPath] {
+				counted[model.LocalPath] = true
+				status.TotalCount++
+				status.TotalBytes += model.Size
+			}
+		}
+		status.Types = append(status.Types, usage)
+
+		for _, dir := range usage.Dirs {
+			if !dirExists(dir) {
+				continue
+			}
+			id := filesystemID(dir)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			free, total, err := diskSpace(dir)
+			if err != nil {
+				log.Printf("Cannot read free space for %s: %v\n", dir, err)
+				continue
+			}
+			status.Filesystems = append(status.Filesystems, FilesystemUsage{Path: dir, FreeBytes: free, TotalBytes: total})
+		}
+	}
+
+	downloads, err := LoadSharedProgress(m.config.ProgressFile())
+	if err != nil {
+		log.Printf("Ignoring download progress: %v\n", err)
+	}
+	status.Downloads = downloads
+
+	partials, err := m.FindPartialDownloads()
+	if err != nil {
+		log.Printf("Cannot look for incomplete downloads: %v\n", err)
+	}
+	status.Partials = partials
+
+	return status, nil
+}
+
+// PrintStatus prints the storage status as a table or, with asJSON, as JSON
+func (m *ModelManager) PrintStatus(asJSON bool) error {
+	status, err := m.StorageStatus()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+
+	fmt.Printf("%-16s %8s %12s\n", "TYPE", "MODELS", "SIZE")
+	for _, usage := range status.Types {
+		fmt.Printf("%-16s %8d %12s\n", usage.Type, usage.Count, formatBytes(usage.Bytes))
+	}
+	fmt.Printf("%-16s %8d %12s\n", "total", status.TotalCount, formatBytes(status.TotalBytes))
+
+	if len(status.Filesystems) > 0 {
+		fmt.Println("\nFree space:")
+		for _, fs := range status.Filesystems {
+			fmt.Printf("  %s: %s free of %s\n", fs.Path,
+				formatBytes(int64(fs.FreeBytes)), formatBytes(int64(fs.TotalBytes)))
+		}
+	}
+
+	if status.Downloads != nil {
+		printSharedProgress(status.Downloads)
+	}
+
+	if len(status.Partials) > 0 {
+		printPartialDownloads(status.Partials)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrUntrusted is returned by a search whose only matches were published
+// by authors outside the trusted lists
+var ErrUntrusted = fmt.Errorf("%w: all by untrusted authors", ErrAllFiltered)
+
+// trustList is a set of trusted authors, compared case-insensitively.
+// A nil list trusts everyone.
+type trustList map[string]bool
+
+// newTrustList builds a list from configured names; none trusts everyone
+func newTrustList(names []string) trustList {
+	if len(names) == 0 {
+		return nil
+	}
+	list := make(trustList, len(names))
+	for _, name := range names {
+		list[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	return list
+}
+
+// Allows reports whether an author is trusted
+func (t trustList) Allows(author string) bool {
+	return t == nil || t[strings.ToLower(author)]
+}
+
+// hfAuthor returns a repository's author, falling back to the owner in
+// its ID when the listing leaves it out
+func hfAuthor(model HFModel) string {
+	if model.Author != "" {
+		return model.Author
+	}
+	owner, _, _ := strings.Cut(model.ID, "/")
+	return owner
+}
+
+// SetAllowUntrusted lets searches return models by any author for this
+// run, ignoring trusted_hf_authors and trusted_civitai_creators
+func (m *ModelManager) SetAllowUntrusted(allow bool) {
+	if allow {
+		m.downloader.hfClient.trusted = nil
+		m.downloader.civitClient.trusted = nil
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// TypeFilter restricts which model types are parsed, scanned and downloaded
+type TypeFilter struct {
+	include map[ModelType]bool // nil includes every type
+	exclude map[ModelType]bool
+}
+
+// ParseTypeFilter builds a filter from comma-separated type lists. Entries
+// may be glob patterns such as "clip*"; each must match a known type.
+func ParseTypeFilter(types, skipTypes string) (*TypeFilter, error) {
+	filter := &TypeFilter{}
+
+	var err error
+	if types != "" {
+		if filter.include, err = matchTypes(types); err != nil {
+			return nil, fmt.Errorf("--types: %w", err)
+		}
+	}
+	if skipTypes != "" {
+		if filter.exclude, err = matchTypes(skipTypes); err != nil {
+			return nil, fmt.Errorf("--skip-types: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+// matchTypes expands a comma-separated list of type names or patterns
+func matchTypes(list string) (map[ModelType]bool, error) {
+	matched := make(map[ModelType]bool)
+
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		found := false
+		for _, modelType := range knownModelTypes {
+			ok, err := path.Match(pattern, string(modelType))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			if ok {
+				matched[modelType] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown model type %q (known types: %s)", pattern, knownTypeNames())
+		}
+	}
+
+	return matched, nil
+}
+
+// knownTypeNames lists the known model types for error messages
+func knownTypeNames() string {
+	names := make([]string, len(knownModelTypes))
+	for i, modelType := range knownModelTypes {
+		names[i] = string(modelType)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Allows reports whether a model type passes the filter. A nil filter
+// allows everything.
+func (f *TypeFilter) Allows(modelType ModelType) bool {
+	if f == nil {
+		return true
+	}
+	if f.include != nil && !f.include[modelType] {
+		return false
+	}
+	return !f.exclude[modelType]
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -14,9 +18,144 @@ type Config struct {
 	HuggingFaceToken string            `json:"huggingface_token"`
 	CivitAIToken     string            `json:"civitai_token"`
 	MaxWorkers       int               `json:"max_workers"`
+	SearchWorkers    int               `json:"search_workers"`
 	ModelDirs        map[string]string `json:"model_dirs"`
-	DownloadTimeout  time.Duration     `json:"download_timeout"`
-	RetryAttempts    int               `json:"retry_attempts"`
+	// DownloadTimeout caps one model's download across all its retries;
+	// 0, the default, leaves slow links to StallTimeout, which aborts an
+	// attempt only once no data arrives
+	DownloadTimeout time.Duration `json:"download_timeout"`
+	StallTimeout    time.Duration `json:"stall_timeout"`
+	RetryAttempts   int           `json:"retry_attempts"`
+	// MaxRetryBackoff caps the jittered delay between retries
+	MaxRetryBackoff time.Duration `json:"max_retry_backoff"`
+	// ModelRetries overrides RetryAttempts for models by name or file name
+	ModelRetries map[string]int `json:"model_retries,omitempty"`
+	// MaxConsecutiveFailures stops starting new downloads once this many
+	// in a row have failed, e.g. during a source outage; 0 never stops
+	MaxConsecutiveFailures int `json:"max_consecutive_failures"`
+	// MaxTotalDownloadBytes caps how much one run downloads, for metered
+	// connections; models past it are left for the next run (0 = no cap)
+	MaxTotalDownloadBytes int64 `json:"max_total_download_bytes"`
+	// ConfirmAboveGB asks for confirmation before a batch larger than
+	// this many gigabytes; 0 never asks
+	ConfirmAboveGB float64 `json:"confirm_above_gb"`
+	// ChunksPerFile splits large downloads into parallel range requests
+	// when the server supports them; 1 downloads in a single stream
+	ChunksPerFile int `json:"chunks_per_file"`
+	// SyncEvery flushes partial downloads to disk every this many bytes,
+	// so a power loss can't leave less on disk than a resume expects;
+	// 0 syncs only when a download ends
+	SyncEvery int64 `json:"sync_every_bytes"`
+	// SourcePriority orders the sources searched per model type; the
+	// "default" key applies to types without their own entry
+	SourcePriority map[string][]string `json:"source_priority,omitempty"`
+	AllowNSFW      bool                `json:"allow_nsfw"`
+	// SearchLimit is how many ranked candidates a search for alternatives
+	// returns across all sources when the caller doesn't say
+	SearchLimit int `json:"search_limit"`
+	// Layout names the folder conventions ModelDirs starts from: "comfyui"
+	// (the default) or "a1111" for Automatic1111 and Forge; model_dirs
+	// entries that differ from ComfyUI's folders override single ones
+	Layout string `json:"layout,omitempty"`
+	// ModelPathOverrides maps a model type to an absolute directory that
+	// takes precedence over ModelDirs
+	ModelPathOverrides map[string]string `json:"model_path_overrides,omitempty"`
+	// ExtraModelDirs lists additional absolute directories searched for
+	// existing models of each type; downloads still go to GetModelDir
+	ExtraModelDirs map[string][]string `json:"extra_model_dirs,omitempty"`
+	// ModelList is a ComfyUI-Manager model-list.json file or URL consulted
+	// before searching HuggingFace and CivitAI
+	ModelList string `json:"model_list,omitempty"`
+	// Aliases maps a workflow model name, matched without regard to case,
+	// to the canonical name of the same model or a URL to download it
+	// from, e.g. {"sdXL_v10.safetensors": "sd_xl_base_1.0.safetensors"}
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// ModelURLs maps a model file name to a download URL that takes
+	// precedence over search, e.g. a Google Drive share, a direct link or
+	// a CivitAI model page
+	ModelURLs map[string]string `json:"model_urls,omitempty"`
+	// ProvenancePath is where the record of downloaded models is kept;
+	// defaults to models/.model-manager.json under ComfyUIPath
+	ProvenancePath string `json:"provenance_path,omitempty"`
+	// CacheDir holds cached search responses; defaults to the user cache
+	// directory. A zero CacheTTL disables caching.
+	CacheDir string        `json:"cache_dir,omitempty"`
+	CacheTTL time.Duration `json:"cache_ttl"`
+	// Download format policy, by file extension (e.g. ".safetensors")
+	AllowedFormats []string `json:"allowed_formats,omitempty"`
+	BlockedFormats []string `json:"blocked_formats,omitempty"`
+	AllowPickle    bool     `json:"allow_pickle"`
+	// MinFileSize rejects a finished download smaller than the size given
+	// for its model type, or the "default" key, e.g. {"checkpoints":
+	// "100MB"}, catching error pages and truncated files when the source
+	// lists no hash; "0" turns a type's check off
+	MinFileSize map[string]string `json:"min_file_size,omitempty"`
+	// PostDownloadHook is a command run after each download, one argument
+	// per element with {path}, {name}, {type} and {source} expanded; the
+	// same values are in MODEL_PATH, MODEL_NAME, MODEL_TYPE, MODEL_SOURCE.
+	// For shell features use ["sh", "-c", "..."] with the variables, not
+	// the placeholders, so file names aren't parsed as shell code.
+	PostDownloadHook []string      `json:"post_download_hook,omitempty"`
+	HookTimeout      time.Duration `json:"hook_timeout"`
+	// A hook exiting non-zero fails the model unless IgnoreHookFailure
+	IgnoreHookFailure bool `json:"ignore_hook_failure"`
+	// FileMode and DirMode ("0664", "0775") are given to downloaded models
+	// and the directories created for them, exactly, ignoring the umask;
+	// empty leaves 0644 and 0755 less the umask. Owner and Group (names or
+	// IDs) chown them, which usually needs root.
+	FileMode string `json:"file_mode,omitempty"`
+	DirMode  string `json:"dir_mode,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+	Group    string `json:"group,omitempty"`
+	// HeuristicInputs maps an input key fragment to a model type for
+	// --heuristic-parse, ahead of the built-in rules, e.g. {"ipadapter":
+	// "clip_vision"}
+	HeuristicInputs map[string]string `json:"heuristic_inputs,omitempty"`
+	// TrustedHFAuthors and TrustedCivitAICreators, when set, limit search
+	// results to models published by these users or organizations, e.g.
+	// ["stabilityai", "black-forest-labs"]; --allow-untrusted overrides
+	TrustedHFAuthors       []string `json:"trusted_hf_authors,omitempty"`
+	TrustedCivitAICreators []string `json:"trusted_civitai_creators,omitempty"`
+	// ComfyUIURL is a running ComfyUI (e.g. http://127.0.0.1:8188) asked
+	// which model files it can load when checking for present models
+	ComfyUIURL string `json:"comfyui_url,omitempty"`
+	// HuggingFaceURL and CivitAIURL replace https://huggingface.co and
+	// https://civitai.com for API requests and downloads, e.g. a mirror
+	// or a self-hosted proxy; HF_ENDPOINT is used when HuggingFaceURL is
+	// empty, as the huggingface_hub tools do
+	HuggingFaceURL string `json:"huggingface_url,omitempty"`
+	CivitAIURL     string `json:"civitai_url,omitempty"`
+	// PreferFormat is the extension picked when a model is offered in
+	// several formats, e.g. both .safetensors and .ckpt
+	PreferFormat string `json:"prefer_format,omitempty"`
+	// SourceHeaders adds headers to every request to a source
+	// ("huggingface", "civitai"), for auth proxies with their own scheme,
+	// e.g. {"civitai": {"X-Api-Key": "{token}", "Authorization": ""}}.
+	// "{token}" is the source's token; an empty value removes the header.
+	SourceHeaders map[string]map[string]string `json:"source_headers,omitempty"`
+	// TokenQueryParam is the query parameter the CivitAI token is added
+	// to download URLs as; empty sends it in the headers only
+	TokenQueryParam string `json:"civitai_token_param"`
+	// ShareProgress saves a running batch's progress every second so
+	// --status in another shell can report it
+	ShareProgress bool `json:"share_progress"`
+	// TempDir holds partial downloads, e.g. fast local scratch when the
+	// models live on a network mount; defaults to beside each model
+	TempDir string `json:"temp_dir,omitempty"`
+	// DisableCompression stops asking the APIs for gzip or deflate
+	// responses, e.g. to debug a proxy that mangles them
+	DisableCompression bool `json:"disable_compression"`
+	// UserAgent overrides the User-Agent sent to HuggingFace and CivitAI
+	UserAgent string `json:"user_agent,omitempty"`
+	// TypeFilter is set from --types/--skip-types for a single run
+	TypeFilter *TypeFilter `json:"-"`
+	// Transport carries the requests of the HuggingFace, CivitAI, direct
+	// and ComfyUI clients; tests can set it to serve canned responses.
+	// nil uses http.DefaultTransport.
+	Transport http.RoundTripper `json:"-"`
+	// DestDir is set from --dest to download under another root while
+	// still checking the ComfyUI directories for existing models
+	DestDir string `json:"-"`
 }
 
 // ModelType represents different types of models in ComfyUI
@@ -30,8 +169,38 @@ const (
 	ModelTypeControlNet ModelType = "controlnet"
 	ModelTypeUpscale    ModelType = "upscale_models"
 	ModelTypeClipVision ModelType = "clip_vision"
+	// Text encoders (CLIP-L/G, T5) used by Flux and SD3. ComfyUI loads them
+	// from text_encoders and still accepts the legacy clip folder.
+	ModelTypeCLIP        ModelType = "clip"
+	ModelTypeTextEncoder ModelType = "text_encoders"
+	// Diffusers-format models are whole folders, loaded by DiffusersLoader
+	ModelTypeDiffusers ModelType = "diffusers"
 )
 
+// knownModelTypes lists every supported model type in display order
+var knownModelTypes = []ModelType{
+	ModelTypeCheckpoint,
+	ModelTypeLora,
+	ModelTypeVAE,
+	ModelTypeEmbedding,
+	ModelTypeControlNet,
+	ModelTypeUpscale,
+	ModelTypeClipVision,
+	ModelTypeCLIP,
+	ModelTypeTextEncoder,
+	ModelTypeDiffusers,
+}
+
+// ParseModelType validates a model type name
+func ParseModelType(name string) (ModelType, error) {
+	for _, modelType := range knownModelTypes {
+		if string(modelType) == name {
+			return modelType, nil
+		}
+	}
+	return "", fmt.Errorf("unknown model type: %s", name)
+}
+
 // Model represents a model referenced in a workflow
 type Model struct {
 	Name        string    `json:"name"`
@@ -42,6 +211,25 @@ type Model struct {
 	LocalPath   string    `json:"local_path,omitempty"`
 	Size        int64     `json:"size,omitempty"`
 	IsPresent   bool      `json:"is_present"`
+	// Retries overrides the configured retry attempts, e.g. from a manifest
+	Retries int `json:"retries,omitempty"`
+	// AIR is the CivitAI AIR identifier the workflow gives for the model
+	AIR string `json:"air,omitempty"`
+
+	// Populated from safetensors metadata by GetModelInfo
+	BaseModel    string    `json:"base_model,omitempty"`
+	Architecture string    `json:"architecture,omitempty"`
+	DetectedType ModelType `json:"detected_type,omitempty"`
+}
+
+// Key identifies a model reference by type and name
+func (m Model) Key() string {
+	return fmt.Sprintf("%s:%s", m.Type, m.Name)
+}
+
+// sameModelType compares types, treating clip and text_encoders as one folder
+func sameModelType(a, b ModelType) bool {
+	return a == b || (isTextEncoderType(a) && isTextEncoderType(b))
 }
 
 // WorkflowNode represents a node in the ComfyUI workflow
@@ -55,31 +243,44 @@ type Workflow map[string]WorkflowNode
 
 // SearchResult represents a model search result from HF or CivitAI
 type SearchResult struct {
-	Name        string
-	Source      string // "huggingface" or "civitai"
-	DownloadURL string
-	Hash        string
-	Size        int64
-	ModelType   ModelType
+	Name        string    `json:"name"`
+	Source      string    `json:"source"` // "huggingface" or "civitai"
+	DownloadURL string    `json:"download_url"`
+	Hash        string    `json:"hash,omitempty"`
+	BLAKE3      string    `json:"blake3,omitempty"` // CivitAI's BLAKE3, faster to check than Hash
+	Size        int64     `json:"size,omitempty"`
+	ModelType   ModelType `json:"model_type"`
+	NSFW        bool      `json:"nsfw,omitempty"` // CivitAI maturity flag
+	Creator     string    `json:"creator,omitempty"`
+	ModelID     int       `json:"civitai_model_id,omitempty"`   // CivitAI model ID
+	VersionID   int       `json:"civitai_version_id,omitempty"` // CivitAI model version ID
+	// DeclaredType is the type the source itself reports for the file,
+	// which may differ from the ModelType that was searched for
+	DeclaredType ModelType `json:"declared_type,omitempty"`
 }
 
 // DefaultConfig returns a default configuration
 func DefaultConfig() *Config {
-	return &Config{
+	config := &Config{
 		ComfyUIPath:     "/workspace/ComfyUI",
 		MaxWorkers:      3,
-		DownloadTimeout: 30 * time.Minute,
+		SearchWorkers:   4,
+		SearchLimit:     5,
+		StallTimeout:    60 * time.Second,
 		RetryAttempts:   3,
-		ModelDirs: map[string]string{
-			string(ModelTypeCheckpoint): "models/checkpoints",
-			string(ModelTypeLora):       "models/loras",
-			string(ModelTypeVAE):        "models/vae",
-			string(ModelTypeEmbedding):  "models/embeddings",
-			string(ModelTypeControlNet): "models/controlnet",
-			string(ModelTypeUpscale):    "models/upscale_models",
-			string(ModelTypeClipVision): "models/clip_vision",
-		},
+		MaxRetryBackoff: 30 * time.Second,
+		ChunksPerFile:   1,
+		SyncEvery:       64 * 1024 * 1024,
+		ConfirmAboveGB:  20,
+		CacheTTL:        24 * time.Hour,
+		AllowPickle:     true,
+		MinFileSize:     map[string]string{"checkpoints": "100MB"},
+		PreferFormat:    "safetensors",
+		HookTimeout:     time.Minute,
+		TokenQueryParam: "token",
 	}
+	config.ModelDirs, _ = layoutDirs("comfyui")
+	return config
 }
 
 // LoadConfig loads configuration from a JSON file
@@ -98,15 +299,141 @@ func LoadConfig(path string) (*Config, error) {
 	if err := json.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	config.applyConfigLayout()
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
 	return config, nil
 }
 
-// GetModelPath returns the full path for a model
-func (c *Config) GetModelPath(modelType ModelType, filename string) string {
+// defaultSourcePriority is the search order used when none is configured
+var defaultSourcePriority = []string{"huggingface", "civitai"}
+
+// SourcesFor returns the ordered list of sources to search for a model type
+func (c *Config) SourcesFor(modelType ModelType) []string {
+	if sources, ok := c.SourcePriority[string(modelType)]; ok && len(sources) > 0 {
+		return sources
+	}
+	if sources, ok := c.SourcePriority["default"]; ok && len(sources) > 0 {
+		return sources
+	}
+	return defaultSourcePriority
+}
+
+// ProvenanceFile returns the path of the downloaded-models record
+func (c *Config) ProvenanceFile() string {
+	if c.ProvenancePath != "" {
+		return c.ProvenancePath
+	}
+	return filepath.Join(c.ComfyUIPath, "models", ".model-manager.json")
+}
+
+// SearchCacheDir returns the directory for cached search responses
+func (c *Config) SearchCacheDir() string {
+	if c.CacheDir != "" {
+		return c.CacheDir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "comfy-model-manager")
+}
+
+// GetModelDir returns the full directory for a model type. Absolute
+// directories are used as-is; relative ones are rooted at ComfyUIPath.
+func (c *Config) GetModelDir(modelType ModelType) string {
+	// Validate rejects relative overrides
+	if dir, ok := c.ModelPathOverrides[string(modelType)]; ok && filepath.IsAbs(dir) {
+		return dir
+	}
+
 	dir, exists := c.ModelDirs[string(modelType)]
 	if !exists {
 		dir = "models/unknown"
 	}
-	return filepath.Join(c.ComfyUIPath, dir, filename)
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(c.ComfyUIPath, dir)
+}
+
+// SearchDirs returns every directory that may hold models of a type,
+// starting with the configured ComfyUI directory
+func (c *Config) SearchDirs(modelType ModelType) []string {
+	dirs := []string{c.GetModelDir(modelType)}
+	extra := c.ExtraModelDirs[string(modelType)]
+	if modelType == ModelTypeTextEncoder {
+		extra = append([]string{c.GetModelDir(ModelTypeCLIP)}, extra...)
+	}
+
+	for _, dir := range extra {
+		if dir != dirs[0] {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// AddExtraModelDirs merges additional search directories into the config
+func (c *Config) AddExtraModelDirs(dirs map[ModelType][]string) {
+	if c.ExtraModelDirs == nil {
+		c.ExtraModelDirs = make(map[string][]string)
+	}
+	for modelType, paths := range dirs {
+		c.ExtraModelDirs[string(modelType)] = append(c.ExtraModelDirs[string(modelType)], paths...)
+	}
+}
+
+// GetModelPath returns the full path a model is downloaded to
+func (c *Config) GetModelPath(modelType ModelType, filename string) string {
+	return filepath.Join(c.downloadDir(modelType), filepath.FromSlash(normalizeModelName(filename)))
+}
+
+// ErrUnsafeModelName is returned for a model name that would be written
+// outside its type's directory
+var ErrUnsafeModelName = errors.New("model name leaves the model directory")
+
+// SafeModelPath returns GetModelPath for a name read from a workflow, PNG
+// or manifest, which may come from anywhere, refusing names that are
+// absolute or climb out of the type's download directory
+func (c *Config) SafeModelPath(modelType ModelType, name string) (string, error) {
+	slashed := normalizeModelName(name)
+	clean := path.Clean(slashed)
+	if path.IsAbs(slashed) || filepath.VolumeName(filepath.FromSlash(slashed)) != "" ||
+		clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeModelName, name)
+	}
+
+	dir := c.downloadDir(modelType)
+	modelPath := c.GetModelPath(modelType, name)
+	rel, err := filepath.Rel(dir, modelPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeModelName, name)
+	}
+	return modelPath, nil
+}
+
+// downloadDir returns the directory downloads of a type are written to:
+// DestDir/<type dir> when DestDir is set, otherwise GetModelDir
+func (c *Config) downloadDir(modelType ModelType) string {
+	if c.DestDir == "" {
+		return c.GetModelDir(modelType)
+	}
+
+	// Keep the relative layout, e.g. <dest>/models/loras
+	dir, exists := c.ModelDirs[string(modelType)]
+	if !exists || filepath.IsAbs(dir) {
+		dir = string(modelType)
+	}
+	return filepath.Join(c.DestDir, dir)
+}
+
+// normalizeModelName converts a workflow model name to forward-slash form.
+// ComfyUI stores subfolder names with the separator of the OS that saved the
+// workflow, so "SDXL\model.safetensors" and "SDXL/model.safetensors" are the same.
+func normalizeModelName(name string) string {
+	return strings.ReplaceAll(name, "\\", "/")
 }
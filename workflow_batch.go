@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// workflowModels holds the model references parsed from one workflow
+type workflowModels struct {
+	Path   string
+	Models []Model
+}
+
+// FindWorkflows recursively lists the .json files in a directory that
+// were modified after since; a zero since lists them all
+func FindWorkflows(dir string, since time.Time) ([]string, error) {
+	var paths []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip files we can't access
+		}
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".json") && info.ModTime().After(since) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning workflow directory: %w", err)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ProcessWorkflowDir processes every workflow in a directory modified after
+// since as one batch, scanning and downloading the union of their models once
+func (m *ModelManager) ProcessWorkflowDir(ctx context.Context, dir string, since time.Time) error {
+	fmt.Printf("Processing workflows in: %s\n", dir)
+
+	paths, err := FindWorkflows(dir, since)
+	if err != nil {
+		return err
+	}
+	if !since.IsZero() {
+		fmt.Printf("Only workflows modified since %s\n", since.Format(time.RFC3339))
+	}
+
+	// Step 1: Parse every workflow, deduplicating models across them
+	fmt.Println("\n1. Parsing workflows...")
+	var parsed []workflowModels
+	union := make(map[string]Model)
+	for _, path := range paths {
+		models, err := m.parser.ParseWorkflow(path)
+		if err != nil {
+			log.Printf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		parsed = append(parsed, workflowModels{Path: path, Models: models})
+		for _, model := range models {
+			union[model.Key()] = model
+		}
+	}
+
+	models := make([]Model, 0, len(union))
+	for _, model := range union {
+		models = append(models, model)
+	}
+	fmt.Printf("Parsed %d of %d workflows, %d unique model references\n",
+		len(parsed), len(paths), len(models))
+
+	// Step 2: Scan the combined set once
+	fmt.Println("\n2. Checking for missing models...")
+	present, missing, err := m.scanner.ScanModels(models)
+	if err != nil {
+		return fmt.Errorf("failed to scan models: %w", err)
+	}
+
+	missingKeys := make(map[string]bool)
+	for _, model := range missing {
+		missingKeys[model.Key()] = true
+	}
+
+	for _, workflow := range parsed {
+		missingCount := 0
+		for _, model := range workflow.Models {
+			if missingKeys[model.Key()] {
+				missingCount++
+			}
+		}
+		rel, _ := filepath.Rel(dir, workflow.Path)
+		fmt.Printf("  %s: %d models, %d missing\n", rel, len(workflow.Models), missingCount)
+	}
+
+	fmt.Printf("Present models: %d\n", len(present))
+	fmt.Printf("Missing models: %d\n", len(missing))
+	present, missing = m.applyForce(present, missing)
+
+	m.lastRun = &RunReport{Workflow: dir, Present: present, Missing: missing}
+	return m.downloadMissing(ctx, missing)
+}
+
+// ParseSince parses a --since value: a duration back from now such as
+// "36h" or "7d", an RFC 3339 timestamp, or a date like "2024-05-01"
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want a duration (36h, 7d), RFC 3339 time or date", value)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// heuristicKeyTypes infers a model type from the input key of an unknown
+// loader node. Earlier entries win, so "clip_vision" is checked before
+// "clip".
+var heuristicKeyTypes = []heuristicInput{
+	{"lora", ModelTypeLora},
+	{"vae", ModelTypeVAE},
+	{"control", ModelTypeControlNet},
+	{"clip_vision", ModelTypeClipVision},
+	{"upscale", ModelTypeUpscale},
+	{"embedding", ModelTypeEmbedding},
+	{"clip", ModelTypeTextEncoder},
+	{"text_encoder", ModelTypeTextEncoder},
+	{"ckpt", ModelTypeCheckpoint},
+	{"checkpoint", ModelTypeCheckpoint},
+}
+
+// heuristicExtensions are the file extensions an input value must end in
+// to be taken for a model reference
+var heuristicExtensions = map[string]bool{
+	".safetensors": true,
+	".sft":         true,
+	".gguf":        true,
+	".ckpt":        true,
+	".pt":          true,
+	".pth":         true,
+	".bin":         true,
+}
+
+// extractHeuristic picks up model files from nodes the parser doesn't
+// know: any string input ending in a model extension, typed by its input
+// key or, failing that, by the folder its value names
+func (p *WorkflowParser) extractHeuristic(node WorkflowNode, modelMap map[string]Model) {
+	for key, input := range node.Inputs {
+		value, ok := input.(string)
+		if !ok || !heuristicExtensions[strings.ToLower(filepath.Ext(value))] {
+			continue
+		}
+
+		modelType, name := p.heuristicType(key, value)
+		if modelType == "" {
+			log.Printf("Heuristic parse: can't tell the type of %s (%s.%s)\n", value, node.ClassType, key)
+			continue
+		}
+		p.addModel(modelMap, modelType, name)
+	}
+}
+
+// heuristicInput maps an input key fragment to a model type
+type heuristicInput struct {
+	key       string
+	modelType ModelType
+}
+
+// customHeuristicInputs returns the configured heuristic_inputs rules,
+// longest key first so the most specific one wins. Validate has already
+// rejected unknown types.
+func customHeuristicInputs(config *Config) []heuristicInput {
+	var rules []heuristicInput
+	for key, name := range config.HeuristicInputs {
+		if modelType, err := ParseModelType(name); err == nil {
+			rules = append(rules, heuristicInput{key: strings.ToLower(key), modelType: modelType})
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].key) != len(rules[j].key) {
+			return len(rules[i].key) > len(rules[j].key)
+		}
+		return rules[i].key < rules[j].key
+	})
+	return rules
+}
+
+// heuristicType infers a model type from an input key, checking the
+// configured heuristic_inputs before the built-in rules, then from the
+// first folder of the value (e.g. "loras/detail.safetensors"), which is
+// then dropped from the returned name
+func (p *WorkflowParser) heuristicType(key, value string) (ModelType, string) {
+	key = strings.ToLower(key)
+
+	for _, rule := range p.customInputs {
+		if strings.Contains(key, rule.key) {
+			return rule.modelType, value
+		}
+	}
+
+	for _, rule := range heuristicKeyTypes {
+		if strings.Contains(key, rule.key) {
+			return rule.modelType, value
+		}
+	}
+
+	if folder, rest, ok := strings.Cut(normalizeModelName(value), "/"); ok {
+		folder = strings.ToLower(folder)
+		if modelType, err := ParseModelType(folder); err == nil {
+			return modelType, rest
+		}
+		if modelType, ok := hfFolderTypes[folder]; ok {
+			return modelType, rest
+		}
+	}
+
+	return "", value
+}
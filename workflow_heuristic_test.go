@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestHeuristicTypeCustomInputs(t *testing.T) {
+	config := newTestConfig(t)
+	config.HeuristicInputs = map[string]string{
+		"IPAdapter":      "clip_vision",
+		"ipadapter_lora": "loras",
+	}
+	parser := NewWorkflowParser(config)
+
+	tests := []struct {
+		key  string
+		want ModelType
+	}{
+		{"ipadapter_file", ModelTypeClipVision},
+		{"ipadapter_lora_name", ModelTypeLora}, // the longer key wins
+		{"vae_name", ModelTypeVAE},             // built-in rules still apply
+	}
+	for _, tt := range tests {
+		if got, _ := parser.heuristicType(tt.key, "model.safetensors"); got != tt.want {
+			t.Errorf("heuristicType(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -1,27 +1,46 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
+	"log"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // WorkflowParser handles parsing ComfyUI workflows
 type WorkflowParser struct {
-	config *Config
+	config       *Config
+	heuristic    bool             // --heuristic-parse: guess models in unknown nodes
+	customInputs []heuristicInput // config's heuristic_inputs, sorted once
 }
 
 // NewWorkflowParser creates a new workflow parser
 func NewWorkflowParser(config *Config) *WorkflowParser {
-	return &WorkflowParser{config: config}
+	return &WorkflowParser{config: config, customInputs: customHeuristicInputs(config)}
 }
 
-// ParseWorkflow parses a workflow file and extracts model references
+// ParseWorkflow parses a workflow file, or a PNG saved by ComfyUI, and
+// extracts model references. A path of "-" reads stdin, and an http(s)
+// URL is fetched.
 func (p *WorkflowParser) ParseWorkflow(path string) ([]Model, error) {
-	data, err := os.ReadFile(path)
+	data, err := p.readWorkflow(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+		return nil, fmt.Errorf("failed to read workflow: %w", err)
+	}
+
+	// ComfyUI embeds the workflow in the images it saves; piped and
+	// fetched images are recognized by their signature
+	if strings.EqualFold(filepath.Ext(path), ".png") || bytes.HasPrefix(data, pngSignature) {
+		if data, err = pngPrompt(data); err != nil {
+			return nil, fmt.Errorf("failed to read workflow from %s: %w", path, err)
+		}
+	}
+
+	if err := validateWorkflow(data); err != nil {
+		return nil, err
 	}
 
 	var workflow Workflow
@@ -38,22 +57,17 @@ func (p *WorkflowParser) extractModels(workflow Workflow) []Model {
 	modelMap := make(map[string]Model)
 
 	for _, node := range workflow {
-		switch node.ClassType {
-		case "CheckpointLoaderSimple", "CheckpointLoader":
-			p.extractCheckpoint(node, modelMap)
-		case "LoraLoader", "LoraLoaderModelOnly":
-			p.extractLora(node, modelMap)
-		case "VAELoader":
-			p.extractVAE(node, modelMap)
-		case "ControlNetLoader":
-			p.extractControlNet(node, modelMap)
-		case "CLIPVisionLoader":
-			p.extractClipVision(node, modelMap)
-		case "UpscaleModelLoader":
-			p.extractUpscaleModel(node, modelMap)
-		default:
-			// Check for embedding references in text fields
-			p.extractEmbeddings(node, modelMap)
+		nodeModels := p.extractNode(node)
+
+		// An AIR in the node pins its model to an exact CivitAI version
+		air := findAIR(node)
+		for key, model := range nodeModels {
+			if air != "" && len(nodeModels) == 1 {
+				model.AIR = air
+			} else if existing, ok := modelMap[key]; ok {
+				model.AIR = existing.AIR
+			}
+			modelMap[key] = model
 		}
 	}
 
@@ -66,62 +80,152 @@ func (p *WorkflowParser) extractModels(workflow Workflow) []Model {
 	return models
 }
 
+// extractNode extracts the model references of a single node
+func (p *WorkflowParser) extractNode(node WorkflowNode) map[string]Model {
+	modelMap := make(map[string]Model)
+	switch node.ClassType {
+	case "CheckpointLoaderSimple", "CheckpointLoader":
+		p.extractCheckpoint(node, modelMap)
+	case "LoraLoader", "LoraLoaderModelOnly":
+		p.extractLora(node, modelMap)
+	case "Power Lora Loader (rgthree)", "Lora Loader Stack (rgthree)", "LoraLoaderStack",
+		"LoraStackLoader", "CR LoRA Stack", "LoRA Stacker":
+		p.extractLoraStack(node, modelMap)
+	case "VAELoader":
+		p.extractVAE(node, modelMap)
+	case "ControlNetLoader":
+		p.extractControlNet(node, modelMap)
+	case "CLIPVisionLoader":
+		p.extractClipVision(node, modelMap)
+	case "CLIPLoader", "DualCLIPLoader", "TripleCLIPLoader":
+		p.extractTextEncoders(node, modelMap)
+	case "UpscaleModelLoader":
+		p.extractUpscaleModel(node, modelMap)
+	default:
+		// Check for embedding references in text fields
+		p.extractEmbeddings(node, modelMap)
+		if p.heuristic {
+			p.extractHeuristic(node, modelMap)
+		}
+	}
+	return modelMap
+}
+
+// addModel records a model reference, deduplicated by type and name
+func (p *WorkflowParser) addModel(modelMap map[string]Model, modelType ModelType, name string) {
+	if !p.config.TypeFilter.Allows(modelType) {
+		return
+	}
+	name = normalizeModelName(name)
+	localPath, err := p.config.SafeModelPath(modelType, name)
+	if err != nil {
+		log.Printf("Skipping %s: %v\n", modelType, err)
+		return
+	}
+	model := Model{
+		Name:      name,
+		Type:      modelType,
+		LocalPath: localPath,
+	}
+	modelMap[model.Key()] = model
+}
+
 // extractCheckpoint extracts checkpoint model references
 func (p *WorkflowParser) extractCheckpoint(node WorkflowNode, modelMap map[string]Model) {
 	if ckptName, ok := node.Inputs["ckpt_name"].(string); ok {
-		key := fmt.Sprintf("%s:%s", ModelTypeCheckpoint, ckptName)
-		modelMap[key] = Model{
-			Name:      ckptName,
-			Type:      ModelTypeCheckpoint,
-			LocalPath: p.config.GetModelPath(ModelTypeCheckpoint, ckptName),
-		}
+		p.addModel(modelMap, ModelTypeCheckpoint, ckptName)
 	}
 }
 
 // extractLora extracts LoRA model references
 func (p *WorkflowParser) extractLora(node WorkflowNode, modelMap map[string]Model) {
 	if loraName, ok := node.Inputs["lora_name"].(string); ok {
-		key := fmt.Sprintf("%s:%s", ModelTypeLora, loraName)
-		modelMap[key] = Model{
-			Name:      loraName,
-			Type:      ModelTypeLora,
-			LocalPath: p.config.GetModelPath(ModelTypeLora, loraName),
+		p.addModel(modelMap, ModelTypeLora, loraName)
+	}
+}
+
+// extractLoraStack extracts every enabled LoRA from stack nodes, which
+// hold either {lora, strength, on} objects under lora_1, lora_2, ... or
+// flat lora_name_1, lora_name_2, ... inputs with optional switch_N toggles
+func (p *WorkflowParser) extractLoraStack(node WorkflowNode, modelMap map[string]Model) {
+	for key, input := range node.Inputs {
+		switch value := input.(type) {
+		case map[string]interface{}:
+			p.addStackedLora(modelMap, value)
+		case []interface{}:
+			for _, item := range value {
+				if entry, ok := item.(map[string]interface{}); ok {
+					p.addStackedLora(modelMap, entry)
+				}
+			}
+		case string:
+			index, ok := loraStackIndex(key)
+			if !ok || value == "" || strings.EqualFold(value, "None") {
+				continue
+			}
+			if toggle, ok := node.Inputs["switch_"+index].(string); ok && strings.EqualFold(toggle, "Off") {
+				continue
+			}
+			// Efficiency's LoRA Stacker keeps entries beyond lora_count
+			if count, ok := node.Inputs["lora_count"].(float64); ok {
+				if n, _ := strconv.Atoi(index); float64(n) > count {
+					continue
+				}
+			}
+			p.addModel(modelMap, ModelTypeLora, value)
+		}
+	}
+}
+
+// addStackedLora adds a {lora, strength, on} stack entry unless disabled
+func (p *WorkflowParser) addStackedLora(modelMap map[string]Model, entry map[string]interface{}) {
+	name, ok := entry["lora"].(string)
+	if !ok || name == "" || strings.EqualFold(name, "None") {
+		return
+	}
+	if on, ok := entry["on"].(bool); ok && !on {
+		return
+	}
+	p.addModel(modelMap, ModelTypeLora, name)
+}
+
+// loraStackIndex returns N for stack input keys lora_name_N and lora_N
+func loraStackIndex(key string) (string, bool) {
+	for _, prefix := range []string{"lora_name_", "lora_"} {
+		if index, ok := strings.CutPrefix(key, prefix); ok && index != "" && strings.Trim(index, "0123456789") == "" {
+			return index, true
 		}
 	}
+	return "", false
 }
 
 // extractVAE extracts VAE model references
 func (p *WorkflowParser) extractVAE(node WorkflowNode, modelMap map[string]Model) {
 	if vaeName, ok := node.Inputs["vae_name"].(string); ok {
-		key := fmt.Sprintf("%s:%s", ModelTypeVAE, vaeName)
-		modelMap[key] = Model{
-			Name:      vaeName,
-			Type:      ModelTypeVAE,
-			LocalPath: p.config.GetModelPath(ModelTypeVAE, vaeName),
-		}
+		p.addModel(modelMap, ModelTypeVAE, vaeName)
 	}
 }
 
 // extractControlNet extracts ControlNet model references
 func (p *WorkflowParser) extractControlNet(node WorkflowNode, modelMap map[string]Model) {
 	if controlNetName, ok := node.Inputs["control_net_name"].(string); ok {
-		key := fmt.Sprintf("%s:%s", ModelTypeControlNet, controlNetName)
-		modelMap[key] = Model{
-			Name:      controlNetName,
-			Type:      ModelTypeControlNet,
-			LocalPath: p.config.GetModelPath(ModelTypeControlNet, controlNetName),
-		}
+		p.addModel(modelMap, ModelTypeControlNet, controlNetName)
 	}
 }
 
 // extractClipVision extracts CLIP Vision model references
 func (p *WorkflowParser) extractClipVision(node WorkflowNode, modelMap map[string]Model) {
 	if clipName, ok := node.Inputs["clip_name"].(string); ok {
-		key := fmt.Sprintf("%s:%s", ModelTypeClipVision, clipName)
-		modelMap[key] = Model{
-			Name:      clipName,
-			Type:      ModelTypeClipVision,
-			LocalPath: p.config.GetModelPath(ModelTypeClipVision, clipName),
+		p.addModel(modelMap, ModelTypeClipVision, clipName)
+	}
+}
+
+// extractTextEncoders extracts text encoder references from the single,
+// dual and triple CLIP loaders
+func (p *WorkflowParser) extractTextEncoders(node WorkflowNode, modelMap map[string]Model) {
+	for _, key := range []string{"clip_name", "clip_name1", "clip_name2", "clip_name3"} {
+		if clipName, ok := node.Inputs[key].(string); ok {
+			p.addModel(modelMap, ModelTypeTextEncoder, clipName)
 		}
 	}
 }
@@ -129,12 +233,7 @@ func (p *WorkflowParser) extractClipVision(node WorkflowNode, modelMap map[strin
 // extractUpscaleModel extracts upscale model references
 func (p *WorkflowParser) extractUpscaleModel(node WorkflowNode, modelMap map[string]Model) {
 	if modelName, ok := node.Inputs["model_name"].(string); ok {
-		key := fmt.Sprintf("%s:%s", ModelTypeUpscale, modelName)
-		modelMap[key] = Model{
-			Name:      modelName,
-			Type:      ModelTypeUpscale,
-			LocalPath: p.config.GetModelPath(ModelTypeUpscale, modelName),
-		}
+		p.addModel(modelMap, ModelTypeUpscale, modelName)
 	}
 }
 
@@ -145,12 +244,7 @@ func (p *WorkflowParser) extractEmbeddings(node WorkflowNode, modelMap map[strin
 		if text, ok := input.(string); ok {
 			embeddings := p.findEmbeddings(text)
 			for _, embedding := range embeddings {
-				key := fmt.Sprintf("%s:%s", ModelTypeEmbedding, embedding)
-				modelMap[key] = Model{
-					Name:      embedding,
-					Type:      ModelTypeEmbedding,
-					LocalPath: p.config.GetModelPath(ModelTypeEmbedding, embedding),
-				}
+				p.addModel(modelMap, ModelTypeEmbedding, embedding)
 			}
 		}
 	}
@@ -170,12 +264,9 @@ func (p *WorkflowParser) findEmbeddings(text string) []string {
 		}
 
 		if endIdx > 0 {
-			embName := parts[i][:endIdx]
-			// Add file extension if not present
-			if !strings.Contains(embName, ".") {
-				embName += ".pt"
-			}
-			embeddings = append(embeddings, embName)
+			// Keep the name as written; the scanner probes .safetensors,
+			// .pt and the other extensions for names without one
+			embeddings = append(embeddings, parts[i][:endIdx])
 		}
 	}
 
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestConfig returns the default config rooted at a temporary ComfyUI
+// install, with response caching off
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+	config := DefaultConfig()
+	config.ComfyUIPath = t.TempDir()
+	config.CacheDir = t.TempDir()
+	config.CacheTTL = 0
+	return config
+}
+
+// newTestManager returns a manager built like NewModelManager's from config
+func newTestManager(config *Config) *ModelManager {
+	manager := &ModelManager{
+		config:     config,
+		parser:     NewWorkflowParser(config),
+		scanner:    NewModelScanner(config),
+		downloader: NewDownloadManager(config),
+	}
+	manager.registerBuiltinResolvers()
+	return manager
+}
+
+// writeFile creates path and its directories with the given content
+func writeFile(t testing.TB, path string, content []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubfolderNameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow string
+	}{
+		{"forward slashes", `{"1": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "a/b/model.safetensors"}}}`},
+		{"backslashes", `{"1": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "a\\b\\model.safetensors"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(t)
+			workflowPath := filepath.Join(t.TempDir(), "workflow.json")
+			writeFile(t, workflowPath, []byte(tt.workflow))
+
+			models, err := NewWorkflowParser(config).ParseWorkflow(workflowPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(models) != 1 {
+				t.Fatalf("got %d models, want 1", len(models))
+			}
+
+			model := models[0]
+			if model.Name != "a/b/model.safetensors" {
+				t.Errorf("Name = %q, want a/b/model.safetensors", model.Name)
+			}
+			wantPath := filepath.Join(config.GetModelDir(ModelTypeCheckpoint), "a", "b", "model.safetensors")
+			if model.LocalPath != wantPath {
+				t.Errorf("LocalPath = %q, want %q", model.LocalPath, wantPath)
+			}
+
+			scanner := NewModelScanner(config)
+			_, missing, err := scanner.ScanModels(models)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(missing) != 1 {
+				t.Fatalf("model reported present before it was written")
+			}
+
+			writeFile(t, wantPath, []byte("weights"))
+			present, _, err := scanner.ScanModels(models)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(present) != 1 {
+				t.Fatalf("model at %s not found", wantPath)
+			}
+
+			// Scanning the directory gives back the workflow's name
+			scanned, err := scanner.ScanDirectory(ModelTypeCheckpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(scanned) != 1 || scanned[0].Name != model.Name {
+				t.Errorf("ScanDirectory = %+v, want one model named %q", scanned, model.Name)
+			}
+		})
+	}
+}
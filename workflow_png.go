@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoEmbeddedWorkflow is returned for PNGs without a ComfyUI prompt
+var ErrNoEmbeddedWorkflow = errors.New("PNG has no embedded ComfyUI workflow")
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngPrompt extracts the API-format workflow ComfyUI embeds in the images
+// it saves, from the "prompt" text chunk. That's the format the parser
+// reads; an image carrying only the UI-format "workflow" chunk is
+// reported as such. Images come from anywhere, so the prompt is only
+// trusted as far as the parser's SafeModelPath check on every name.
+func pngPrompt(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not a PNG file")
+	}
+
+	hasUIWorkflow := false
+	rest := data[len(pngSignature):]
+	for len(rest) >= 12 {
+		length := binary.BigEndian.Uint32(rest[:4])
+		chunkType := string(rest[4:8])
+		if uint64(length) > uint64(len(rest)-12) {
+			return nil, fmt.Errorf("truncated %s chunk", chunkType)
+		}
+		body := rest[8 : 8+length]
+		rest = rest[12+length:]
+
+		if chunkType == "IEND" {
+			break
+		}
+		keyword, text, err := pngTextChunk(chunkType, body)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s chunk: %w", chunkType, err)
+		}
+		switch keyword {
+		case "prompt":
+			return text, nil
+		case "workflow":
+			hasUIWorkflow = true
+		}
+	}
+
+	if hasUIWorkflow {
+		return nil, ErrUIWorkflow
+	}
+	return nil, ErrNoEmbeddedWorkflow
+}
+
+// pngTextChunk decodes the keyword and text of a tEXt, zTXt or iTXt
+// chunk; other chunks have no keyword
+func pngTextChunk(chunkType string, body []byte) (string, []byte, error) {
+	switch chunkType {
+	case "tEXt", "zTXt", "iTXt":
+	default:
+		return "", nil, nil
+	}
+
+	keyword, text, ok := bytes.Cut(body, []byte{0})
+	if !ok {
+		return "", nil, fmt.Errorf("no keyword")
+	}
+
+	compressed := false
+	switch chunkType {
+	case "zTXt":
+		if len(text) < 1 {
+			return "", nil, fmt.Errorf("no compression method")
+		}
+		compressed, text = true, text[1:]
+	case "iTXt":
+		// Compression flag and method, then language tag and translated
+		// keyword, each NUL-terminated
+		if len(text) < 2 {
+			return "", nil, fmt.Errorf("no compression flag")
+		}
+		compressed = text[0] == 1
+		fields := bytes.SplitN(text[2:], []byte{0}, 3)
+		if len(fields) < 3 {
+			return "", nil, fmt.Errorf("no language tag")
+		}
+		text = fields[2]
+	}
+
+	if compressed {
+		r, err := zlib.NewReader(bytes.NewReader(text))
+		if err != nil {
+			return "", nil, err
+		}
+		defer r.Close()
+		if text, err = io.ReadAll(r); err != nil {
+			return "", nil, err
+		}
+	}
+	return string(keyword), text, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"path/filepath"
+	"testing"
+)
+
+// pngWithPrompt returns a 1x1 PNG carrying prompt in a tEXt chunk after
+// IHDR, as ComfyUI saves its images
+func pngWithPrompt(t *testing.T, prompt string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	body := append([]byte("prompt\x00"), prompt...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	ihdrEnd := len(pngSignature) + 12 + 13
+	return append(append(append([]byte(nil), data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+}
+
+func TestPNGWorkflowRejectsTraversal(t *testing.T) {
+	pngPath := filepath.Join(t.TempDir(), "image.png")
+	writeFile(t, pngPath, pngWithPrompt(t, `{
+		"1": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "../../../evil.safetensors"}},
+		"2": {"class_type": "LoraLoader", "inputs": {"lora_name": "add_detail.safetensors"}}
+	}`))
+
+	models, err := NewWorkflowParser(newTestConfig(t)).ParseWorkflow(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].Name != "add_detail.safetensors" {
+		t.Errorf("got %+v, want only add_detail.safetensors", models)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxWorkflowSize bounds workflows read from stdin or a URL; API-format
+// workflows are kilobytes, PNGs saved by ComfyUI a few megabytes
+const maxWorkflowSize = 64 * 1024 * 1024
+
+// readWorkflow reads a workflow from a file, from stdin when path is "-",
+// or from an http(s) URL
+func (p *WorkflowParser) readWorkflow(path string) ([]byte, error) {
+	switch {
+	case path == "-":
+		return readLimited(os.Stdin, "stdin")
+	case strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://"):
+		return p.fetchWorkflow(path)
+	default:
+		return os.ReadFile(path)
+	}
+}
+
+// fetchWorkflow downloads a workflow, rejecting web pages and responses
+// larger than maxWorkflowSize
+func (p *WorkflowParser) fetchWorkflow(workflowURL string) ([]byte, error) {
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: newHeaderTransport(p.config, "Accept", "application/json, image/png"),
+	}
+
+	req, err := http.NewRequest("GET", workflowURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPStatusError("workflow fetch failed", resp, false)
+	}
+	if resp.ContentLength > maxWorkflowSize {
+		return nil, fmt.Errorf("workflow at %s is %s, more than the %s limit",
+			workflowURL, formatBytes(resp.ContentLength), formatBytes(maxWorkflowSize))
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+		return nil, fmt.Errorf("%s returned a web page, not a workflow; link to the raw file", workflowURL)
+	}
+
+	return readLimited(resp.Body, workflowURL)
+}
+
+// readLimited reads a workflow stream up to maxWorkflowSize
+func readLimited(r io.Reader, source string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxWorkflowSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxWorkflowSize {
+		return nil, fmt.Errorf("workflow from %s is larger than %s", source, formatBytes(maxWorkflowSize))
+	}
+	return data, nil
+}
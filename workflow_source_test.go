@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchWorkflowSendsUserAgent(t *testing.T) {
+	var userAgent, accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, accept = r.Header.Get("User-Agent"), r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	config := newTestConfig(t)
+	config.UserAgent = "model-manager-test"
+	if _, err := NewWorkflowParser(config).fetchWorkflow(server.URL + "/workflow.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if userAgent != "model-manager-test" {
+		t.Errorf("User-Agent = %q, want the configured one", userAgent)
+	}
+	if accept != "application/json, image/png" {
+		t.Errorf("Accept = %q, want application/json, image/png", accept)
+	}
+}
+
+func TestFetchedWorkflowSkipsUnsafeNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"1": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "../../x.safetensors"}},
+			"2": {"class_type": "LoraLoader", "inputs": {"lora_name": "..\\..\\..\\y.safetensors"}},
+			"3": {"class_type": "VAELoader", "inputs": {"vae_name": "sdxl/../sdxl_vae.safetensors"}}
+		}`))
+	}))
+	defer server.Close()
+
+	config := newTestConfig(t)
+	models, err := NewWorkflowParser(config).ParseWorkflow(server.URL + "/workflow.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the name that stays inside its directory is kept
+	if len(models) != 1 || models[0].Type != ModelTypeVAE {
+		t.Fatalf("got %+v, want just the VAE", models)
+	}
+	if want := filepath.Join(config.GetModelDir(ModelTypeVAE), "sdxl_vae.safetensors"); models[0].LocalPath != want {
+		t.Errorf("LocalPath = %q, want %q", models[0].LocalPath, want)
+	}
+}
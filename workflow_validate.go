@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrNotJSON is returned for workflow files that don't parse as JSON
+	ErrNotJSON = errors.New("not valid JSON")
+	// ErrNotWorkflow is returned for JSON that isn't a ComfyUI workflow
+	ErrNotWorkflow = errors.New("valid JSON but not a ComfyUI workflow")
+	// ErrEmptyWorkflow is returned for workflows without any nodes
+	ErrEmptyWorkflow = errors.New("empty workflow")
+	// ErrUIWorkflow is returned for workflows saved in the UI format
+	ErrUIWorkflow = errors.New("workflow is in the UI format; export it with \"Save (API Format)\"")
+)
+
+// validateWorkflow checks that data looks like an API-format ComfyUI
+// workflow, explaining what is wrong when it doesn't
+func validateWorkflow(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := lineAndColumn(data, syntaxErr.Offset)
+			return fmt.Errorf("%w: %v at line %d, column %d", ErrNotJSON, err, line, col)
+		}
+		return fmt.Errorf("%w: %v", ErrNotJSON, err)
+	}
+
+	nodes, ok := raw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: top level is %s, not an object of nodes", ErrNotWorkflow, jsonKind(raw))
+	}
+	if len(nodes) == 0 {
+		return ErrEmptyWorkflow
+	}
+	if _, ok := nodes["nodes"].([]interface{}); ok {
+		return ErrUIWorkflow
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		node, ok := nodes[id].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: node %q is %s, not an object", ErrNotWorkflow, id, jsonKind(nodes[id]))
+		}
+		if classType, ok := node["class_type"].(string); !ok || classType == "" {
+			return fmt.Errorf("%w: node %q has no class_type", ErrNotWorkflow, id)
+		}
+		if inputs, exists := node["inputs"]; exists {
+			if _, ok := inputs.(map[string]interface{}); !ok {
+				return fmt.Errorf("%w: inputs of node %q are %s, not an object", ErrNotWorkflow, id, jsonKind(inputs))
+			}
+		}
+	}
+
+	return nil
+}
+
+// lineAndColumn converts a byte offset into a 1-based line and column
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// jsonKind names the JSON type of a decoded value for error messages
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}